@@ -1,90 +1,154 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/panaiotuzunov/gator/internal/api"
+	"github.com/panaiotuzunov/gator/internal/auth"
 	"github.com/panaiotuzunov/gator/internal/config"
+	"github.com/panaiotuzunov/gator/internal/cursor"
 	"github.com/panaiotuzunov/gator/internal/database"
 	"github.com/panaiotuzunov/gator/internal/fetch"
+	"github.com/panaiotuzunov/gator/internal/migrate"
+	"github.com/panaiotuzunov/gator/internal/opml"
+	"github.com/panaiotuzunov/gator/internal/render"
+	"golang.org/x/term"
 )
 
-type state struct {
-	db  *database.Queries
-	cfg *config.Config
-}
+const (
+	defaultMaxFeedFailures = 10
+	maxBackoff             = 24 * time.Hour
+	shutdownTimeout        = 10 * time.Second
+	discoverTimeout        = time.Minute
+	leaseMargin            = time.Minute
+	tokenLifetime          = 30 * 24 * time.Hour
+	searchLimit            = 10
+	maxBrowseLimit         = 100
+)
 
-type command struct {
-	name string
-	args []string
-}
+var stdin = bufio.NewReader(os.Stdin)
 
-type commands struct {
-	list map[string]func(*state, command) error
+type state struct {
+	db         database.Store
+	conn       *sql.DB
+	migrations fs.FS
+	cfg        *config.Config
 }
 
-func (c *commands) run(s *state, cmd command) error {
-	function, ok := c.list[cmd.name]
-	if !ok {
-		return fmt.Errorf("error: function does not exist")
-	}
-	err := function(s, cmd)
+// withTx runs fn with a copy of the state whose queries run in a single
+// transaction, committing if fn succeeds and rolling back otherwise. fn must
+// only use the state it is given: SQLite has a single connection, so a query
+// through s.db would wait on the transaction forever.
+func (s *state) withTx(fn func(tx *state) error) error {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
 	if err != nil {
+		return fmt.Errorf("error starting transaction - %v", err)
+	}
+	defer tx.Rollback()
+	txState := *s
+	txState.db = s.db.Tx(tx)
+	if err := fn(&txState); err != nil {
 		return err
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction - %v", err)
+	}
 	return nil
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
-	c.list[name] = f
-}
-
 func handlerLogin(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("error: the login command accepts exactly one argument - username")
-	}
 	usernameStr := cmd.args[0]
-	_, err := s.db.GetUserByName(context.Background(), usernameStr)
+	user, err := s.db.GetUserByName(context.Background(), usernameStr)
 	if err == sql.ErrNoRows {
 		return fmt.Errorf("error: user %s does not exist", usernameStr)
 	} else if err != nil {
 		return fmt.Errorf("error: database error - %v", err)
 	}
-	err = s.cfg.SetUser(usernameStr)
+	if !user.PasswordHash.Valid {
+		// Accounts created before passwords existed cannot be claimed by
+		// whoever logs in first; an administrator has to set a password.
+		return fmt.Errorf("error: user %s has no password - an administrator has to set one, see 'gator help hashpassword'", usernameStr)
+	}
+	password, err := readPassword("Password: ")
 	if err != nil {
-		return fmt.Errorf("error updating config: %v", err)
+		return fmt.Errorf("error reading password - %v", err)
+	}
+	if err := auth.CheckPasswordHash(password, user.PasswordHash.String); err != nil {
+		return fmt.Errorf("error: incorrect password for user %s", usernameStr)
+	}
+	if err := startSession(s, user); err != nil {
+		return err
 	}
 	fmt.Printf("The user %s logged in successfully.\n", usernameStr)
 	return nil
 }
 
-func handlerRegister(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("error: the register command accepts exactly one argument - username")
+func handlerLogout(s *state, cmd command) error {
+	if s.cfg.SessionToken != "" {
+		if err := s.db.DeleteApiToken(context.Background(), auth.HashToken(s.cfg.SessionToken)); err != nil {
+			return fmt.Errorf("error revoking session - %v", err)
+		}
 	}
+	if err := s.cfg.SetSession("", ""); err != nil {
+		return fmt.Errorf("error updating config: %v", err)
+	}
+	fmt.Println("Logged out successfully.")
+	return nil
+}
+
+func handlerRegister(s *state, cmd command) error {
 	usernameStr := cmd.args[0]
 	_, err := s.db.GetUserByName(context.Background(), usernameStr)
 	if err == sql.ErrNoRows {
+		passwordHash, err := promptNewPassword()
+		if err != nil {
+			return err
+		}
 		userData := database.CreateUserParams{
-			ID:        uuid.New(),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-			Name:      usernameStr,
+			ID:           uuid.New(),
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+			Name:         usernameStr,
+			PasswordHash: sql.NullString{String: passwordHash, Valid: true},
 		}
-		CreatedUserData, err := s.db.CreateUser(context.Background(), userData)
+		// The user and its first session are stored together, so a register
+		// that fails before the commit can simply be retried.
+		var CreatedUserData database.User
+		var token string
+		err = s.withTx(func(tx *state) error {
+			var err error
+			CreatedUserData, err = tx.db.CreateUser(context.Background(), userData)
+			if err != nil {
+				return fmt.Errorf("error creating user %s", usernameStr)
+			}
+			token, _, err = createApiToken(tx, CreatedUserData.ID, tokenLifetime)
+			return err
+		})
 		if err != nil {
-			return fmt.Errorf("error creating user %s", usernameStr)
+			return err
 		}
-		err = s.cfg.SetUser(usernameStr)
-		if err != nil {
-			return fmt.Errorf("error updating config: %v", err)
+		if err := saveSession(s, CreatedUserData.Name, token); err != nil {
+			return fmt.Errorf("user %s was created, but logging in failed - %v. Run 'gator login %s' to retry", usernameStr, err, usernameStr)
 		}
-		fmt.Printf("User %s created successfully. User parameters:\n%v\n", usernameStr, CreatedUserData)
+		fmt.Printf("User %s created successfully. User ID: %v\n", usernameStr, CreatedUserData.ID)
 	} else if err != nil {
 		return fmt.Errorf("error: database error - %v", err)
 	} else {
@@ -93,6 +157,130 @@ func handlerRegister(s *state, cmd command) error {
 	return nil
 }
 
+// handlerSetPassword changes the logged-in user's password after checking
+// the current one, and revokes every other session and API token of theirs.
+func handlerSetPassword(s *state, cmd command, user database.User) error {
+	if cmd.args[0] != user.Name {
+		return fmt.Errorf("error: you can only change your own password, log in as %s first", cmd.args[0])
+	}
+	password, err := readPassword("Current password: ")
+	if err != nil {
+		return fmt.Errorf("error reading password - %v", err)
+	}
+	if err := auth.CheckPasswordHash(password, user.PasswordHash.String); err != nil {
+		return fmt.Errorf("error: incorrect password for user %s", user.Name)
+	}
+	passwordHash, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+	err = s.withTx(func(tx *state) error {
+		passwordParams := database.SetUserPasswordParams{
+			PasswordHash: sql.NullString{String: passwordHash, Valid: true},
+			UpdatedAt:    time.Now(),
+			ID:           user.ID,
+		}
+		if err := tx.db.SetUserPassword(context.Background(), passwordParams); err != nil {
+			return fmt.Errorf("error setting password - %v", err)
+		}
+		if err := tx.db.DeleteApiTokensForUser(context.Background(), user.ID); err != nil {
+			return fmt.Errorf("error revoking sessions - %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := startSession(s, user); err != nil {
+		return err
+	}
+	fmt.Printf("Password for %s changed. Your other sessions and API tokens were revoked.\n", user.Name)
+	return nil
+}
+
+// handlerHashPassword prints the hash of a new password without touching
+// the database. Accounts created before passwords existed cannot be claimed
+// through gator itself; an administrator with write access to the database
+// stores the hash for them instead.
+func handlerHashPassword(s *state, cmd command) error {
+	passwordHash, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+	fmt.Println(passwordHash)
+	return nil
+}
+
+func handlerApiToken(s *state, cmd command, user database.User) error {
+	lifetime := tokenLifetime
+	if len(cmd.args) == 1 {
+		var err error
+		lifetime, err = time.ParseDuration(cmd.args[0])
+		if err != nil || lifetime <= 0 {
+			return fmt.Errorf("error: token lifetime must be a positive duration")
+		}
+	}
+	token, expiresAt, err := createApiToken(s, user.ID, lifetime)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("API token for %s (expires %v):\n%s\n", user.Name, expiresAt.Format("02/01/2006 15:04"), token)
+	return nil
+}
+
+type migrationRow struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+func handlerMigrate(s *state, cmd command) error {
+	migrator, err := migrate.New(s.conn, s.migrations)
+	if err != nil {
+		return fmt.Errorf("error loading migrations - %v", err)
+	}
+	switch cmd.args[0] {
+	case "up":
+		applied, err := migrator.Up(context.Background())
+		for _, migration := range applied {
+			fmt.Printf("Applied %s\n", migration.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("error migrating up - %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("The database schema is up to date.")
+		}
+	case "down":
+		migration, err := migrator.Down(context.Background())
+		if err != nil {
+			return fmt.Errorf("error migrating down - %v", err)
+		}
+		if migration == nil {
+			fmt.Println("There are no migrations to roll back.")
+			return nil
+		}
+		fmt.Printf("Rolled back %s\n", migration.Name)
+	case "status":
+		statuses, err := migrator.Status(context.Background())
+		if err != nil {
+			return fmt.Errorf("error getting migration status - %v", err)
+		}
+		rows := make([]migrationRow, len(statuses))
+		for i, status := range statuses {
+			rows[i] = migrationRow{Version: status.Version, Name: status.Name, Applied: status.AppliedAt.Valid}
+			if status.AppliedAt.Valid {
+				rows[i].AppliedAt = &status.AppliedAt.Time
+			}
+		}
+		return render.Write(os.Stdout, cmd.output, rows)
+	default:
+		return fmt.Errorf("error: unknown action %q, expected up, down or status", cmd.args[0])
+	}
+	return nil
+}
+
 func handlerReset(s *state, cmd command) error {
 	err := s.db.DeleteUsers(context.Background())
 	if err != nil {
@@ -102,88 +290,262 @@ func handlerReset(s *state, cmd command) error {
 	return nil
 }
 
+type userRow struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+}
+
 func handlerUsers(s *state, cmd command) error {
 	users, err := s.db.GetUsers(context.Background())
 	if err != nil {
 		return fmt.Errorf("error: Reading users failed - %v", err)
 	}
-	for _, user := range users {
-		if user == s.cfg.CurrentUserName {
-			fmt.Printf("* %s (current)\n", user)
-			continue
-		}
-		fmt.Printf("* %s\n", user)
+	rows := make([]userRow, len(users))
+	for i, user := range users {
+		rows[i] = userRow{Name: user, Current: user == s.cfg.CurrentUserName}
 	}
-	return nil
+	return writeRows(cmd, rows, "There are no users.")
 }
 
 func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("error: the aggregate command accepts exactly one argument - time between requests (1m0s)")
-	}
 	time_between_reqs, err := time.ParseDuration(cmd.args[0])
 	if err != nil {
 		return fmt.Errorf("error parsing time between requests arguments - %v", err)
 	}
-	fmt.Printf("Collecting feeds every %s\n", cmd.args[0])
+	workers := 1
+	if len(cmd.args) == 2 {
+		workers, err = strconv.Atoi(cmd.args[1])
+		if err != nil || workers < 1 {
+			return fmt.Errorf("error: number of workers must be a positive integer")
+		}
+	}
+	fmt.Printf("Collecting feeds every %s with %d worker(s)\n", cmd.args[0], workers)
 	ticker := time.NewTicker(time_between_reqs)
 	for ; ; <-ticker.C {
-		if err := scrapeFeeds(s); err != nil {
-			return fmt.Errorf("error scraping feeds - %v", err)
+		if err := scrapeFeedsConcurrently(s, workers, time_between_reqs); err != nil {
+			fmt.Printf("error scraping feeds - %v\n", err)
 		}
 	}
 }
 
+// scrapeFeedsConcurrently fetches every due feed with a pool of workers.
+// Each worker keeps leasing the next due feed until none is left, and the
+// errors of all feeds are returned together. A feed is fetched at most once
+// per call, so the round ends even if a feed is due again by the time its
+// fetch finishes.
+func scrapeFeedsConcurrently(s *state, workers int, defaultInterval time.Duration) error {
+	roundStartedAt := time.Now()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	report := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				nextFeed, err := leaseNextFeed(s, defaultInterval, roundStartedAt)
+				if err == sql.ErrNoRows {
+					return
+				} else if err != nil {
+					// The next lease would most likely fail the same way.
+					report(err)
+					return
+				}
+				if err := scrapeFeed(s, nextFeed, defaultInterval); err != nil {
+					report(fmt.Errorf("feed %s: %w", nextFeed.Name, err))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
 func handlerAddFeed(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 2 {
-		return fmt.Errorf("error: the addfeed command accepts exactly two argument - name, url")
+	// Discovery may try several candidate URLs, each with its own request
+	// timeout, so the whole search gets a deadline as well.
+	ctx, cancel := context.WithTimeout(context.Background(), discoverTimeout)
+	defer cancel()
+	feeds, err := fetch.Discover(ctx, cmd.args[1])
+	if err != nil {
+		return fmt.Errorf("error finding a feed at %s - %v", cmd.args[1], err)
+	}
+	discovered, err := chooseFeed(cmd.args[1], feeds)
+	if err != nil {
+		return err
+	}
+	if discovered.URL != cmd.args[1] {
+		fmt.Printf("Using feed %s\n", discovered.URL)
 	}
 	feedParams := database.CreateFeedParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		Name:      cmd.args[0],
-		Url:       cmd.args[1],
+		Url:       discovered.URL,
 		UserID:    user.ID,
 	}
-	feed, err := s.db.CreateFeed(context.Background(), feedParams)
+	var feed database.Feed
+	err = s.withTx(func(tx *state) error {
+		var err error
+		feed, err = tx.db.CreateFeed(context.Background(), feedParams)
+		if err != nil {
+			return fmt.Errorf("error creating feed - %v", err)
+		}
+		feedFollowParams := database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		}
+		if _, err := tx.db.CreateFeedFollow(context.Background(), feedFollowParams); err != nil {
+			return fmt.Errorf("error creating feed follow - %v", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("error creating feed - %v", err)
+		return err
 	}
-	feedFollowParams := database.CreateFeedFollowParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		UserID:    user.ID,
-		FeedID:    feed.ID,
+	fmt.Printf("%+v\n", feed)
+	return nil
+}
+
+// chooseFeed asks the user which feed to add when a page offers several.
+func chooseFeed(pageURL string, feeds []fetch.DiscoveredFeed) (fetch.DiscoveredFeed, error) {
+	if len(feeds) == 1 {
+		return feeds[0], nil
+	}
+	fmt.Printf("Found %d feeds at %s:\n", len(feeds), pageURL)
+	for i, feed := range feeds {
+		title := feed.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		fmt.Printf("  %d. %s - %s\n", i+1, title, feed.URL)
+	}
+	fmt.Printf("Choose a feed [1-%d]: ", len(feeds))
+	line, err := stdin.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return fetch.DiscoveredFeed{}, fmt.Errorf("error reading choice - %v", err)
 	}
-	_, err = s.db.CreateFeedFollow(context.Background(), feedFollowParams)
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(feeds) {
+		return fetch.DiscoveredFeed{}, fmt.Errorf("error: choice must be a number between 1 and %d", len(feeds))
+	}
+	return feeds[choice-1], nil
+}
+
+func handlerSetInterval(s *state, cmd command) error {
+	interval, err := time.ParseDuration(cmd.args[1])
 	if err != nil {
-		return fmt.Errorf("error creating feed follow - %v", err)
+		return fmt.Errorf("error parsing interval - %v", err)
 	}
-	fmt.Printf("%+v\n", feed)
+	// Zero resets the feed to the default interval. Anything else is stored
+	// as whole seconds, so it has to fit the column and not round to zero.
+	if interval != 0 && (interval < time.Second || interval.Seconds() > math.MaxInt32) {
+		return fmt.Errorf("error: interval must be 0 or between 1s and %s", time.Duration(math.MaxInt32)*time.Second)
+	}
+	intervalParams := database.SetFeedFetchIntervalParams{
+		FetchIntervalSeconds: sql.NullInt32{Int32: int32(interval.Seconds()), Valid: interval > 0},
+		UpdatedAt:            time.Now(),
+		Url:                  cmd.args[0],
+	}
+	feed, err := s.db.SetFeedFetchInterval(context.Background(), intervalParams)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("error: feed %s does not exist", cmd.args[0])
+	} else if err != nil {
+		return fmt.Errorf("error setting feed interval - %v", err)
+	}
+	if interval == 0 {
+		fmt.Printf("Feed %s now uses the default interval.\n", feed.Name)
+		return nil
+	}
+	fmt.Printf("Feed %s will be fetched every %s.\n", feed.Name, interval)
+	return nil
+}
+
+func handlerEnableFeed(s *state, cmd command) error {
+	enableParams := database.EnableFeedParams{
+		UpdatedAt: time.Now(),
+		Url:       cmd.args[0],
+	}
+	feed, err := s.db.EnableFeed(context.Background(), enableParams)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("error: feed %s does not exist", cmd.args[0])
+	} else if err != nil {
+		return fmt.Errorf("error enabling feed - %v", err)
+	}
+	fmt.Printf("Feed %s enabled.\n", feed.Name)
 	return nil
 }
 
+type feedErrorRow struct {
+	Name              string     `json:"name"`
+	URL               string     `json:"url"`
+	ConsecutiveErrors int32      `json:"consecutive_errors"`
+	LastStatus        *int32     `json:"last_status"`
+	LastError         *string    `json:"last_error"`
+	LastSucceededAt   *time.Time `json:"last_succeeded_at"`
+	DisabledAt        *time.Time `json:"disabled_at"`
+	NextFetchAt       *time.Time `json:"next_fetch_at"`
+}
+
+func handlerFeedErrors(s *state, cmd command) error {
+	feeds, err := s.db.GetFeedsWithErrors(context.Background())
+	if err != nil {
+		return fmt.Errorf("error getting failing feeds - %v", err)
+	}
+	rows := make([]feedErrorRow, len(feeds))
+	for i, feed := range feeds {
+		rows[i] = feedErrorRow{Name: feed.Name, URL: feed.Url, ConsecutiveErrors: feed.ConsecutiveErrors}
+		if feed.LastStatusCode.Valid {
+			rows[i].LastStatus = &feed.LastStatusCode.Int32
+		}
+		if feed.LastError.Valid {
+			rows[i].LastError = &feed.LastError.String
+		}
+		if feed.LastSucceededAt.Valid {
+			rows[i].LastSucceededAt = &feed.LastSucceededAt.Time
+		}
+		// A disabled feed is not attempted again until it is enabled.
+		if feed.DisabledAt.Valid {
+			rows[i].DisabledAt = &feed.DisabledAt.Time
+		} else if feed.NextFetchAt.Valid {
+			rows[i].NextFetchAt = &feed.NextFetchAt.Time
+		}
+	}
+	return writeRows(cmd, rows, "There are no failing feeds.")
+}
+
+type feedRow struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	User string `json:"user"`
+}
+
 func handlerGetFeeds(s *state, cmd command) error {
 	feeds, err := s.db.GetFeeds(context.Background())
 	if err != nil {
 		return fmt.Errorf("error getting feeds - %v", err)
 	}
-	for _, feed := range feeds {
+	rows := make([]feedRow, len(feeds))
+	for i, feed := range feeds {
 		user, err := s.db.GetUser(context.Background(), feed.UserID)
 		if err != nil {
 			return fmt.Errorf("error getting user name - %v", err)
 		}
-		fmt.Printf("name - %v, url - %v, user - %v\n", feed.Name, feed.Url, user.Name)
+		rows[i] = feedRow{Name: feed.Name, URL: feed.Url, User: user.Name}
 	}
-	return nil
+	return writeRows(cmd, rows, "There are no feeds.")
 }
 
 func handlerFollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("error: the follow command accepts exactly one argument - url")
-	}
 	feedData, err := s.db.GetFeedByUrl(context.Background(), cmd.args[0])
 	if err != nil {
 		return fmt.Errorf("error getting feed data - %v", err)
@@ -203,24 +565,31 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 	return nil
 }
 
+type followingRow struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Category string `json:"category"`
+	Unread   int64  `json:"unread"`
+}
+
 func handlerFollowing(s *state, cmd command, user database.User) error {
 	feedFollowsResult, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
 	if err != nil {
 		return fmt.Errorf("error getting current user feed follows - %v", err)
 	}
-	if len(feedFollowsResult) == 0 {
-		return fmt.Errorf("error: the current user doesn't follow any feeds")
-	}
-	for _, feedFollow := range feedFollowsResult {
-		fmt.Printf("%+v\n", feedFollow)
+	rows := make([]followingRow, len(feedFollowsResult))
+	for i, feedFollow := range feedFollowsResult {
+		rows[i] = followingRow{
+			Name:     feedFollow.FeedName,
+			URL:      feedFollow.FeedUrl,
+			Category: feedFollow.Category.String,
+			Unread:   feedFollow.UnreadCount,
+		}
 	}
-	return nil
+	return writeRows(cmd, rows, "You are not following any feeds.")
 }
 
 func handlerUnfollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("error: the unfollow command accepts exactly one argument - url")
-	}
 	feed, err := s.db.GetFeedByUrl(context.Background(), cmd.args[0])
 	if err != nil {
 		return fmt.Errorf("error getting feed data - %v", err)
@@ -236,71 +605,653 @@ func handlerUnfollow(s *state, cmd command, user database.User) error {
 	return nil
 }
 
+func handlerImport(s *state, cmd command, user database.User) error {
+	file, err := os.Open(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("error opening OPML file - %v", err)
+	}
+	defer file.Close()
+	subscriptions, err := opml.Parse(file)
+	if err != nil {
+		return fmt.Errorf("error parsing OPML file - %v", err)
+	}
+	var created, followedCount, skipped int
+	err = s.withTx(func(tx *state) error {
+		feedFollows, err := tx.db.GetFeedFollowsForUser(context.Background(), user.ID)
+		if err != nil {
+			return fmt.Errorf("error getting current user feed follows - %v", err)
+		}
+		followed := make(map[uuid.UUID]bool)
+		for _, feedFollow := range feedFollows {
+			followed[feedFollow.FeedID] = true
+		}
+		for _, subscription := range subscriptions {
+			feed, err := tx.db.GetFeedByUrl(context.Background(), subscription.URL)
+			if err == sql.ErrNoRows {
+				feedParams := database.CreateFeedParams{
+					ID:        uuid.New(),
+					CreatedAt: time.Now(),
+					UpdatedAt: time.Now(),
+					Name:      subscription.Title,
+					Url:       subscription.URL,
+					UserID:    user.ID,
+				}
+				feed, err = tx.db.CreateFeed(context.Background(), feedParams)
+				if err != nil {
+					return fmt.Errorf("error creating feed %s - %v", subscription.URL, err)
+				}
+				created++
+			} else if err != nil {
+				return fmt.Errorf("error getting feed data - %v", err)
+			}
+			if followed[feed.ID] {
+				skipped++
+				continue
+			}
+			feedFollowParams := database.CreateFeedFollowParams{
+				ID:        uuid.New(),
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+				UserID:    user.ID,
+				FeedID:    feed.ID,
+				Category:  sql.NullString{String: subscription.Category, Valid: subscription.Category != ""},
+			}
+			if _, err := tx.db.CreateFeedFollow(context.Background(), feedFollowParams); err != nil {
+				return fmt.Errorf("error creating feed follow for %s - %v", subscription.URL, err)
+			}
+			followed[feed.ID] = true
+			followedCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d feeds: %d created, %d followed, %d skipped.\n", len(subscriptions), created, followedCount, skipped)
+	return nil
+}
+
+func handlerExport(s *state, cmd command, user database.User) error {
+	feedFollows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting current user feed follows - %v", err)
+	}
+	subscriptions := make([]opml.Subscription, 0, len(feedFollows))
+	for _, feedFollow := range feedFollows {
+		subscriptions = append(subscriptions, opml.Subscription{
+			Title:    feedFollow.FeedName,
+			URL:      feedFollow.FeedUrl,
+			Category: feedFollow.Category.String,
+		})
+	}
+	out := os.Stdout
+	if len(cmd.args) == 1 {
+		out, err = os.Create(cmd.args[0])
+		if err != nil {
+			return fmt.Errorf("error creating output file - %v", err)
+		}
+		defer out.Close()
+	}
+	title := fmt.Sprintf("%s subscriptions in gator", user.Name)
+	if err := opml.Write(out, title, subscriptions); err != nil {
+		return fmt.Errorf("error writing OPML - %v", err)
+	}
+	if len(cmd.args) == 1 {
+		fmt.Printf("Exported %d feeds to %s.\n", len(subscriptions), cmd.args[0])
+	}
+	return nil
+}
+
+// postRow is a post as listed by browse. Cursor is the --after value that
+// continues the listing after this post.
+type postRow struct {
+	ID          uuid.UUID `json:"id"`
+	Feed        string    `json:"feed"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+	Read        bool      `json:"read"`
+	Updated     bool      `json:"updated"`
+	Starred     bool      `json:"starred"`
+	Cursor      string    `json:"cursor,omitempty" table:"-"`
+}
+
+// browseFlags declares the filters accepted by the browse command.
+func browseFlags(flags *flag.FlagSet) {
+	flags.Bool("unread", false, "only show unread posts")
+	flags.Bool("mark-read", false, "mark the shown posts as read")
+	flags.String("feed", "", "only show posts from the feed with this name or URL")
+	flags.String("since", "", "only show posts published after this time (24h, 7d or 2006-01-02)")
+	flags.String("before", "", "only show posts published before this time (24h, 7d or 2006-01-02)")
+	flags.String("keyword", "", "only show posts whose title or description contains this text")
+	flags.String("sort", "newest", "sort order - newest, oldest or feed")
+	flags.String("after", "", "cursor printed by the previous page")
+}
+
 func handlerBrowse(s *state, cmd command, user database.User) error {
+	unreadOnly := cmd.flagBool("unread")
+	markRead := cmd.flagBool("mark-read")
+	feed := cmd.flagString("feed")
+	since := cmd.flagString("since")
+	before := cmd.flagString("before")
+	keyword := cmd.flagString("keyword")
+	sortOrder := cmd.flagString("sort")
+	after := cmd.flagString("after")
 	postLimit := int32(2)
-	if len(cmd.args) > 0 {
+	if len(cmd.args) == 1 {
 		limit, err := strconv.Atoi(cmd.args[0])
-		if err != nil {
-			return fmt.Errorf("error parsing posts limit - %v", err)
+		if err != nil || limit < 1 || limit > maxBrowseLimit {
+			return fmt.Errorf("error: posts limit must be between 1 and %d", maxBrowseLimit)
 		}
 		postLimit = int32(limit)
 	}
+	if sortOrder != "newest" && sortOrder != "oldest" && sortOrder != "feed" {
+		return fmt.Errorf("error: sort order must be newest, oldest or feed")
+	}
+	sinceTime, err := parseTimeFilter(since)
+	if err != nil {
+		return fmt.Errorf("error parsing --since - %v", err)
+	}
+	beforeTime, err := parseTimeFilter(before)
+	if err != nil {
+		return fmt.Errorf("error parsing --before - %v", err)
+	}
 	getPostsParams := database.GetPostsForUserParams{
-		UserID: user.ID,
-		Limit:  postLimit,
+		UserID:     user.ID,
+		UnreadOnly: unreadOnly,
+		Feed:       sql.NullString{String: feed, Valid: feed != ""},
+		Since:      sinceTime,
+		Before:     beforeTime,
+		Keyword:    sql.NullString{String: likeEscaper.Replace(keyword), Valid: keyword != ""},
+		SortOrder:  sortOrder,
+		Limit:      postLimit,
+	}
+	if after != "" {
+		if sortOrder == "feed" {
+			return fmt.Errorf("error: --after cannot be combined with --sort feed")
+		}
+		publishedAt, id, err := cursor.Decode(after)
+		if err != nil {
+			return fmt.Errorf("error parsing --after - %v", err)
+		}
+		getPostsParams.AfterPublishedAt = sql.NullTime{Time: publishedAt, Valid: true}
+		getPostsParams.AfterID = uuid.NullUUID{UUID: id, Valid: true}
 	}
 	posts, err := s.db.GetPostsForUser(context.Background(), getPostsParams)
 	if err != nil {
 		return fmt.Errorf("error getting posts - %v", err)
 	}
-	if len(posts) == 0 {
-		fmt.Println("There are no posts to display.")
-	}
+	rows := make([]postRow, len(posts))
 	for i, post := range posts {
-		i++
-		fmt.Printf("=== Post %d ===\n", i)
-		fmt.Printf("Title: %s\n", post.Title)
-		fmt.Printf("URL: %s\n", post.Url)
-		fmt.Printf("Description: %s\n", post.Description)
-		fmt.Printf("Published: %v\n", post.PublishedAt.Format("02/01/2006"))
-		fmt.Println()
+		rows[i] = postRow{
+			ID:          post.ID,
+			Feed:        post.FeedName,
+			Title:       post.Title,
+			URL:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			Read:        post.IsRead,
+			Updated:     post.Updated,
+			Starred:     post.Starred,
+		}
+		if sortOrder != "feed" {
+			rows[i].Cursor = cursor.Encode(post.PublishedAt, post.ID)
+		}
+	}
+	if markRead {
+		// All shown posts are marked together, so a failure leaves none of
+		// them marked.
+		err := s.withTx(func(tx *state) error {
+			for _, post := range posts {
+				if post.IsRead {
+					continue
+				}
+				if err := markPostRead(tx, user.ID, post.ID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := writeRows(cmd, rows, "There are no posts to display."); err != nil {
+		return err
+	}
+	if cmd.output == render.Table && len(rows) == int(postLimit) && rows[len(rows)-1].Cursor != "" {
+		fmt.Printf("\nNext page: --after %s\n", rows[len(rows)-1].Cursor)
+	}
+	return nil
+}
+
+// likeEscaper escapes the LIKE wildcards in a --keyword value, so it is
+// matched literally. The queries declare backslash as the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// parseTimeFilter accepts either a duration counted back from now (24h, or
+// 7d for days) or an absolute date. An empty value means no filter.
+func parseTimeFilter(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return sql.NullTime{Time: time.Now().AddDate(0, 0, -n), Valid: true}, nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return sql.NullTime{Time: time.Now().Add(-duration), Valid: true}, nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return sql.NullTime{Time: t, Valid: true}, nil
+		}
+	}
+	return sql.NullTime{}, fmt.Errorf("unable to parse %q as a duration or date", value)
+}
+
+type searchRow struct {
+	ID          uuid.UUID `json:"id"`
+	Feed        string    `json:"feed"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Match       string    `json:"match"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+func handlerSearch(s *state, cmd command, user database.User) error {
+	searchParams := database.SearchPostsForUserParams{
+		Query:  strings.Join(cmd.args, " "),
+		UserID: user.ID,
+		Limit:  searchLimit,
+	}
+	results, err := s.db.SearchPostsForUser(context.Background(), searchParams)
+	if err != nil {
+		return fmt.Errorf("error searching posts - %v", err)
+	}
+	rows := make([]searchRow, len(results))
+	for i, result := range results {
+		rows[i] = searchRow{
+			ID:          result.ID,
+			Feed:        result.FeedName,
+			Title:       result.Title,
+			URL:         result.Url,
+			Match:       result.Snippet,
+			PublishedAt: result.PublishedAt,
+		}
+	}
+	return writeRows(cmd, rows, "No posts match the search.")
+}
+
+func handlerRead(s *state, cmd command, user database.User) error {
+	postID, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("error parsing post ID - %v", err)
+	}
+	if err := markPostRead(s, user.ID, postID); err != nil {
+		return err
+	}
+	fmt.Println("Post marked as read.")
+	return nil
+}
+
+func handlerUnread(s *state, cmd command, user database.User) error {
+	postID, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("error parsing post ID - %v", err)
+	}
+	unreadParams := database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: postID,
+	}
+	if err := s.db.MarkPostUnread(context.Background(), unreadParams); err != nil {
+		return fmt.Errorf("error marking post as unread - %v", err)
+	}
+	fmt.Println("Post marked as unread.")
+	return nil
+}
+
+func handlerMarkAll(s *state, cmd command, user database.User) error {
+	if cmd.args[0] != "read" {
+		return fmt.Errorf("error: unknown action %q, the only supported action is read", cmd.args[0])
+	}
+	markParams := database.MarkAllPostsReadParams{
+		UserID: user.ID,
+		ReadAt: time.Now(),
+	}
+	if len(cmd.args) == 2 {
+		feed, err := s.db.GetFeedByUrl(context.Background(), cmd.args[1])
+		if err != nil {
+			return fmt.Errorf("error getting feed data - %v", err)
+		}
+		markParams.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	marked, err := s.db.MarkAllPostsRead(context.Background(), markParams)
+	if err != nil {
+		return fmt.Errorf("error marking posts as read - %v", err)
+	}
+	fmt.Printf("Marked %d posts as read.\n", marked)
+	return nil
+}
+
+func handlerStar(s *state, cmd command, user database.User) error {
+	postID, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("error parsing post ID - %v", err)
+	}
+	starParams := database.StarPostParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		PostID:    postID,
+	}
+	starred, err := s.db.StarPost(context.Background(), starParams)
+	if err != nil {
+		return fmt.Errorf("error starring post - %v", err)
+	}
+	if starred == 0 {
+		return fmt.Errorf("error: post %s does not exist or is already starred", postID)
+	}
+	fmt.Println("Post starred.")
+	return nil
+}
+
+func handlerUnstar(s *state, cmd command, user database.User) error {
+	id, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("error parsing ID - %v", err)
+	}
+	unstarParams := database.UnstarPostParams{
+		UserID: user.ID,
+		ID:     id,
+	}
+	unstarred, err := s.db.UnstarPost(context.Background(), unstarParams)
+	if err != nil {
+		return fmt.Errorf("error unstarring post - %v", err)
+	}
+	if unstarred == 0 {
+		return fmt.Errorf("error: post %s is not starred", id)
+	}
+	fmt.Println("Post unstarred.")
+	return nil
+}
+
+type starredRow struct {
+	ID          uuid.UUID  `json:"id"`
+	PostID      *uuid.UUID `json:"post_id" table:"-"`
+	Feed        string     `json:"feed"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Description string     `json:"description"`
+	PublishedAt time.Time  `json:"published_at"`
+	StarredAt   time.Time  `json:"starred_at"`
+}
+
+func handlerStarred(s *state, cmd command, user database.User) error {
+	stars, err := s.db.GetStarredPostsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting starred posts - %v", err)
+	}
+	rows := make([]starredRow, len(stars))
+	for i, star := range stars {
+		rows[i] = starredRow{
+			ID:          star.ID,
+			Feed:        star.FeedName,
+			Title:       star.Title,
+			URL:         star.Url,
+			Description: star.Description,
+			PublishedAt: star.PublishedAt,
+			StarredAt:   star.CreatedAt,
+		}
+		// The post itself may have been deleted since it was starred.
+		if star.PostID.Valid {
+			rows[i].PostID = &star.PostID.UUID
+		}
+	}
+	return writeRows(cmd, rows, "There are no starred posts.")
+}
+
+func markPostRead(s *state, userID, postID uuid.UUID) error {
+	readParams := database.MarkPostReadParams{
+		UserID: userID,
+		PostID: postID,
+		ReadAt: time.Now(),
+	}
+	if err := s.db.MarkPostRead(context.Background(), readParams); err != nil {
+		return fmt.Errorf("error marking post as read - %v", err)
+	}
+	return nil
+}
+
+func handlerServe(s *state, cmd command) error {
+	server := &http.Server{
+		Addr:              cmd.args[0],
+		Handler:           api.NewServer(s.db, s.conn).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	fmt.Printf("Serving the API on %s\n", cmd.args[0])
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("error serving the API - %v", err)
+	case <-ctx.Done():
+	}
+	fmt.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error shutting down the server - %v", err)
 	}
 	return nil
 }
 
 func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
 	return func(s *state, cmd command) error {
-		if s.cfg.CurrentUserName == "" {
+		if s.cfg.SessionToken == "" {
 			return fmt.Errorf("no user is currently logged in")
 		}
-		currentUserStruct, err := s.db.GetUserByName(context.Background(), s.cfg.CurrentUserName)
-		if err != nil {
-			return fmt.Errorf("error reading user by name from DB - %v", err)
+		tokenHash := auth.HashToken(s.cfg.SessionToken)
+		userParams := database.GetUserByApiTokenParams{
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now(),
+		}
+		currentUserStruct, err := s.db.GetUserByApiToken(context.Background(), userParams)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("the session has expired, please log in again")
+		} else if err != nil {
+			return fmt.Errorf("error reading user by session token from DB - %v", err)
+		}
+		touchParams := database.TouchApiTokenParams{
+			LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			TokenHash:  tokenHash,
+		}
+		if err := s.db.TouchApiToken(context.Background(), touchParams); err != nil {
+			return fmt.Errorf("error updating session - %v", err)
 		}
 		return handler(s, cmd, currentUserStruct)
 	}
 }
 
-func scrapeFeeds(s *state) error {
-	nextFeed, err := s.db.GetNextFeedToFetch(context.Background())
+// startSession issues a session token for user and saves it in the config.
+// The token is stored first so the config never refers to a token that does
+// not exist.
+func startSession(s *state, user database.User) error {
+	token, _, err := createApiToken(s, user.ID, tokenLifetime)
 	if err != nil {
-		return fmt.Errorf("error getting next feed to fetch - %v", err)
+		return err
 	}
-	markFeedParams := database.MarkFeedFetchedParams{
-		LastFetchedAt: sql.NullTime{Time: time.Now(), Valid: true},
-		ID:            nextFeed.ID,
+	return saveSession(s, user.Name, token)
+}
+
+// saveSession writes a stored session token to the config. If the config
+// cannot be written the token is revoked again rather than left valid with
+// nobody holding it.
+func saveSession(s *state, userName, token string) error {
+	if err := s.cfg.SetSession(userName, token); err != nil {
+		if deleteErr := s.db.DeleteApiToken(context.Background(), auth.HashToken(token)); deleteErr != nil {
+			return fmt.Errorf("error updating config: %v (revoking the session also failed - %v)", err, deleteErr)
+		}
+		return fmt.Errorf("error updating config: %v", err)
 	}
-	s.db.MarkFeedFetched(context.Background(), markFeedParams)
-	feed, err := fetch.FetchFeed(context.Background(), nextFeed.Url)
+	return nil
+}
+
+func createApiToken(s *state, userID uuid.UUID, lifetime time.Duration) (string, time.Time, error) {
+	token, err := auth.MakeToken()
 	if err != nil {
-		return err
+		return "", time.Time{}, fmt.Errorf("error generating token - %v", err)
+	}
+	tokenParams := database.CreateApiTokenParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    userID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(lifetime),
+	}
+	apiToken, err := s.db.CreateApiToken(context.Background(), tokenParams)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error storing token - %v", err)
+	}
+	return token, apiToken.ExpiresAt, nil
+}
+
+func promptNewPassword() (string, error) {
+	password, err := readPassword("Password: ")
+	if err != nil {
+		return "", fmt.Errorf("error reading password - %v", err)
+	}
+	if len(password) < auth.MinPasswordLength {
+		return "", fmt.Errorf("error: password must be at least %d characters long", auth.MinPasswordLength)
+	}
+	confirmation, err := readPassword("Confirm password: ")
+	if err != nil {
+		return "", fmt.Errorf("error reading password - %v", err)
+	}
+	if password != confirmation {
+		return "", fmt.Errorf("error: passwords do not match")
+	}
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password - %v", err)
+	}
+	return passwordHash, nil
+}
+
+// readPassword reads a password without echoing it when stdin is a terminal,
+// and a plain line otherwise so scripts can pipe it in.
+// readPassword prompts on stderr, so a command's own output can be piped.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		password, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// leaseNextFeed returns the next due feed that has not been fetched since
+// roundStartedAt, or sql.ErrNoRows when there is none. The feed is leased for
+// the default interval, but at least long enough for the request to time out
+// and its posts to be saved, so other workers skip it while it is being
+// fetched even if this attempt never finishes.
+func leaseNextFeed(s *state, defaultInterval time.Duration, roundStartedAt time.Time) (database.Feed, error) {
+	now := time.Now()
+	lease := max(defaultInterval, fetch.RequestTimeout+leaseMargin)
+	nextFeedParams := database.GetNextFeedToFetchParams{
+		LastFetchedAt:  sql.NullTime{Time: now, Valid: true},
+		NextFetchAt:    sql.NullTime{Time: now.Add(lease), Valid: true},
+		RoundStartedAt: sql.NullTime{Time: roundStartedAt, Valid: true},
+	}
+	nextFeed, err := s.db.GetNextFeedToFetch(context.Background(), nextFeedParams)
+	if err != nil && err != sql.ErrNoRows {
+		return database.Feed{}, fmt.Errorf("error getting next feed to fetch - %v", err)
+	}
+	return nextFeed, err
+}
+
+func scrapeFeed(s *state, nextFeed database.Feed, defaultInterval time.Duration) error {
+	fetchedAt := time.Now()
+	interval := defaultInterval
+	if nextFeed.FetchIntervalSeconds.Valid {
+		interval = time.Duration(nextFeed.FetchIntervalSeconds.Int32) * time.Second
+	}
+	cache := fetch.CacheHeaders{
+		ETag:         nextFeed.Etag.String,
+		LastModified: nextFeed.LastModified.String,
+	}
+	feed, newCache, err := fetch.FetchFeed(context.Background(), nextFeed.Url, cache)
+	if errors.Is(err, fetch.ErrNotModified) {
+		fmt.Printf("Feed %s not modified since last fetch. Skipping...\n", nextFeed.Name)
+		return s.withTx(func(tx *state) error {
+			if err := recordFeedSuccess(tx, nextFeed.ID, http.StatusNotModified); err != nil {
+				return err
+			}
+			// A 304 has no body, so the hints are the ones the feed sent last.
+			hints := decodeRefreshHints(nextFeed.RefreshHints)
+			return scheduleNextFetch(tx, nextFeed.ID, hints.NextFetch(fetchedAt, interval))
+		})
+	}
+	if err != nil {
+		return s.withTx(func(tx *state) error {
+			return recordFeedFailure(tx, nextFeed, interval, err)
+		})
+	}
+	failed := savePosts(s, nextFeed.ID, feed.Channel.Item)
+	return s.withTx(func(tx *state) error {
+		if err := recordFeedSuccess(tx, nextFeed.ID, http.StatusOK); err != nil {
+			return err
+		}
+		// The validators are only kept once every post is stored. Otherwise
+		// the next fetch would be answered with 304 Not Modified and the posts
+		// that failed would never be fetched again.
+		if failed == 0 {
+			updateCacheParams := database.UpdateFeedCacheParams{
+				Etag:         sql.NullString{String: newCache.ETag, Valid: newCache.ETag != ""},
+				LastModified: sql.NullString{String: newCache.LastModified, Valid: newCache.LastModified != ""},
+				ID:           nextFeed.ID,
+			}
+			if err := tx.db.UpdateFeedCache(context.Background(), updateCacheParams); err != nil {
+				return fmt.Errorf("error updating feed cache headers - %v", err)
+			}
+		}
+		hintsParams := database.UpdateFeedRefreshHintsParams{
+			RefreshHints: encodeRefreshHints(feed.Channel.RefreshHints),
+			ID:           nextFeed.ID,
+		}
+		if err := tx.db.UpdateFeedRefreshHints(context.Background(), hintsParams); err != nil {
+			return fmt.Errorf("error updating feed refresh hints - %v", err)
+		}
+		return scheduleNextFetch(tx, nextFeed.ID, feed.Channel.NextFetch(fetchedAt, interval))
+	})
+}
+
+// savePosts stores the items of a fetched feed and returns how many of them
+// could not be saved.
+func savePosts(s *state, feedID uuid.UUID, items []fetch.RSSItem) int {
+	links := make(map[string]int)
+	for _, item := range items {
+		links[item.Link]++
 	}
-	for _, item := range feed.Channel.Item {
+	failed := 0
+	for _, item := range items {
 		parsedTime, err := parsePublishDate(item.PubDate)
 		if err != nil {
-			return fmt.Errorf("error parsing date: %v", err)
+			fmt.Printf("Post %s has an invalid date - %v. Skipping...\n", item.Title, err)
+			continue
 		}
-		postParams := database.CreatePostParams{
+		postParams := database.UpsertPostParams{
 			ID:          uuid.New(),
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
@@ -308,18 +1259,165 @@ func scrapeFeeds(s *state) error {
 			Url:         item.Link,
 			Description: item.Description,
 			PublishedAt: parsedTime,
-			FeedID:      nextFeed.ID,
+			FeedID:      feedID,
+			Guid:        item.Identifier(),
 		}
-		_, err = s.db.CreatePost(context.Background(), postParams)
-		if err != nil {
-			if strings.Contains(err.Error(), "UNIQUE constraint failed") ||
-				strings.Contains(err.Error(), "duplicate key") {
-				fmt.Printf("Post %s already exists. Skipping... \n", item.Title)
+		if strings.TrimSpace(item.GUID) == "" && item.Link != "" && links[item.Link] == 1 {
+			guid, err := postGuidByLink(s, feedID, item.Link)
+			if err != nil {
+				fmt.Printf("Creating post %s failed with error - %v. Skipping...\n", item.Title, err)
+				failed++
 				continue
 			}
+			if guid != "" {
+				postParams.Guid = guid
+			}
+		}
+		// The post and its previous revision are saved together, so an edit
+		// whose revision could not be saved is picked up again next fetch.
+		updated := false
+		err = s.withTx(func(tx *state) error {
+			post, err := tx.db.UpsertPost(context.Background(), postParams)
+			if err != nil {
+				return err
+			}
+			if !post.PreviousTitle.Valid {
+				return nil
+			}
+			revisionParams := database.CreatePostRevisionParams{
+				ID:          uuid.New(),
+				CreatedAt:   time.Now(),
+				PostID:      post.ID,
+				Title:       post.PreviousTitle.String,
+				Description: post.PreviousDescription.String,
+			}
+			if err := tx.db.CreatePostRevision(context.Background(), revisionParams); err != nil {
+				return fmt.Errorf("error saving previous revision - %v", err)
+			}
+			updated = true
+			return nil
+		})
+		if err == sql.ErrNoRows {
+			fmt.Printf("Post %s already exists. Skipping... \n", item.Title)
+			continue
+		} else if err != nil {
 			fmt.Printf("Creating post %s failed with error - %v. Skipping...\n", item.Title, err)
+			failed++
+			continue
+		}
+		if updated {
+			fmt.Printf("Post %s was updated upstream.\n", item.Title)
+		}
+	}
+	return failed
+}
+
+// postGuidByLink returns the key of the post in the feed that has link as its
+// URL, so an item without a GUID whose title was corrected upstream updates
+// that post instead of creating a new one. It returns "" unless exactly one
+// post has the link, since items that share a link cannot be told apart.
+func postGuidByLink(s *state, feedID uuid.UUID, link string) (string, error) {
+	guids, err := s.db.GetPostGuidsByUrl(context.Background(), database.GetPostGuidsByUrlParams{
+		FeedID: feedID,
+		Url:    link,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(guids) != 1 {
+		return "", nil
+	}
+	return guids[0], nil
+}
+
+func recordFeedSuccess(s *state, feedID uuid.UUID, statusCode int) error {
+	successParams := database.RecordFeedSuccessParams{
+		LastStatusCode:  sql.NullInt32{Int32: int32(statusCode), Valid: true},
+		LastSucceededAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:              feedID,
+	}
+	if err := s.db.RecordFeedSuccess(context.Background(), successParams); err != nil {
+		return fmt.Errorf("error recording feed success - %v", err)
+	}
+	return nil
+}
+
+// recordFeedFailure stores the fetch error on the feed and backs off its next
+// fetch, disabling the feed once it has failed too many times in a row.
+func recordFeedFailure(s *state, feed database.Feed, interval time.Duration, fetchErr error) error {
+	fmt.Printf("Fetching feed %s failed - %v\n", feed.Name, fetchErr)
+	failureParams := database.RecordFeedFailureParams{
+		LastError: sql.NullString{String: fetchErr.Error(), Valid: true},
+		ID:        feed.ID,
+	}
+	var statusErr *fetch.StatusError
+	if errors.As(fetchErr, &statusErr) {
+		failureParams.LastStatusCode = sql.NullInt32{Int32: int32(statusErr.StatusCode), Valid: true}
+	}
+	failedFeed, err := s.db.RecordFeedFailure(context.Background(), failureParams)
+	if err != nil {
+		return fmt.Errorf("error recording feed failure - %v", err)
+	}
+	maxFailures := s.cfg.MaxFeedFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxFeedFailures
+	}
+	if int(failedFeed.ConsecutiveErrors) >= maxFailures {
+		disableParams := database.DisableFeedParams{
+			DisabledAt: sql.NullTime{Time: time.Now(), Valid: true},
+			ID:         feed.ID,
+		}
+		if err := s.db.DisableFeed(context.Background(), disableParams); err != nil {
+			return fmt.Errorf("error disabling feed - %v", err)
 		}
+		fmt.Printf("Feed %s disabled after %d consecutive failures.\n", feed.Name, failedFeed.ConsecutiveErrors)
+		return nil
+	}
+	return scheduleNextFetch(s, feed.ID, time.Now().Add(backoff(interval, failedFeed.ConsecutiveErrors)))
+}
 
+// backoff doubles the interval for every consecutive failure after the
+// first, up to maxBackoff.
+func backoff(interval time.Duration, failures int32) time.Duration {
+	delay := interval
+	for i := int32(1); i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// encodeRefreshHints returns the hints as stored on the feed: JSON, or NULL
+// when the feed gave none.
+func encodeRefreshHints(hints fetch.RefreshHints) sql.NullString {
+	if hints.IsZero() {
+		return sql.NullString{}
+	}
+	encoded, err := json.Marshal(hints)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(encoded), Valid: true}
+}
+
+// decodeRefreshHints returns the hints stored on a feed. Missing or
+// unreadable hints mean the feed gave none.
+func decodeRefreshHints(stored sql.NullString) fetch.RefreshHints {
+	var hints fetch.RefreshHints
+	if stored.Valid {
+		if err := json.Unmarshal([]byte(stored.String), &hints); err != nil {
+			return fetch.RefreshHints{}
+		}
+	}
+	return hints
+}
+
+func scheduleNextFetch(s *state, feedID uuid.UUID, next time.Time) error {
+	scheduleParams := database.ScheduleNextFetchParams{
+		NextFetchAt: sql.NullTime{Time: next, Valid: true},
+		ID:          feedID,
+	}
+	if err := s.db.ScheduleNextFetch(context.Background(), scheduleParams); err != nil {
+		return fmt.Errorf("error scheduling next fetch - %v", err)
 	}
 	return nil
 }
@@ -330,6 +1428,8 @@ func parsePublishDate(dateStr string) (time.Time, error) {
 		time.RFC822,                      // "Mon, 02 Jan 2006 15:04:05 MST" (timezone abbreviation)
 		time.RFC3339,                     // "2006-01-02T15:04:05Z07:00"
 		"Mon, 2 Jan 2006 15:04:05 -0700", // Single digit day with numeric timezone
+		"2006-01-02T15:04Z07:00",         // W3C-DTF without seconds (Dublin Core dc:date)
+		"2006-01-02",                     // W3C-DTF date only
 	}
 
 	for _, format := range formats {
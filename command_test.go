@@ -0,0 +1,956 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/panaiotuzunov/gator/internal/auth"
+	"github.com/panaiotuzunov/gator/internal/config"
+	"github.com/panaiotuzunov/gator/internal/database"
+	"github.com/panaiotuzunov/gator/internal/fetch"
+	"github.com/panaiotuzunov/gator/internal/render"
+	"github.com/panaiotuzunov/gator/internal/storetest"
+)
+
+const testPassword = "correct horse"
+
+// newTestState returns a state backed by a fresh in-memory database. HOME
+// points at a temporary directory since logging in writes the config file.
+func newTestState(t *testing.T) *state {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	db := storetest.Open(t)
+	return &state{
+		db:         db.Store,
+		conn:       db.Conn,
+		migrations: db.Migrations,
+		cfg:        &config.Config{DbUrl: "sqlite::memory:"},
+	}
+}
+
+// runCommand runs args through the command registry as main would, feeding
+// input to password prompts, and returns what the command printed.
+func runCommand(t *testing.T, s *state, input string, args ...string) (string, error) {
+	t.Helper()
+	stdin = bufio.NewReader(strings.NewReader(input))
+	cmd := command{name: args[0], args: args[1:], output: render.Table}
+	var err error
+	output := captureStdout(t, func() {
+		err = registerCommands().run(cmd, func(bool) (*state, error) { return s, nil })
+	})
+	return output, err
+}
+
+func mustRun(t *testing.T, s *state, input string, args ...string) string {
+	t.Helper()
+	output, err := runCommand(t, s, input, args...)
+	if err != nil {
+		t.Fatalf("gator %s: %v", strings.Join(args, " "), err)
+	}
+	return output
+}
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("creating pipe: %v", err)
+	}
+	original := os.Stdout
+	os.Stdout = writer
+	var output strings.Builder
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		io.Copy(&output, reader)
+	}()
+	defer func() {
+		os.Stdout = original
+		writer.Close()
+		wg.Wait()
+		reader.Close()
+	}()
+	fn()
+	writer.Close()
+	wg.Wait()
+	return output.String()
+}
+
+// register creates name and leaves them logged in.
+func register(t *testing.T, s *state, name string) database.User {
+	t.Helper()
+	mustRun(t, s, testPassword+"\n"+testPassword+"\n", "register", name)
+	user, err := s.db.GetUserByName(context.Background(), name)
+	if err != nil {
+		t.Fatalf("getting registered user %s: %v", name, err)
+	}
+	return user
+}
+
+func followedURLs(t *testing.T, s *state, user database.User) []string {
+	t.Helper()
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("getting follows: %v", err)
+	}
+	var urls []string
+	for _, follow := range follows {
+		urls = append(urls, follow.FeedUrl)
+	}
+	return urls
+}
+
+func TestRegister(t *testing.T) {
+	s := newTestState(t)
+	user := register(t, s, "alice")
+	if !user.PasswordHash.Valid {
+		t.Error("registered user has no password hash")
+	}
+	if s.cfg.CurrentUserName != "alice" || s.cfg.SessionToken == "" {
+		t.Errorf("after registering, config has user %q and token %q, want alice and a session", s.cfg.CurrentUserName, s.cfg.SessionToken)
+	}
+	saved, err := config.Read()
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if saved.SessionToken != s.cfg.SessionToken {
+		t.Error("the session token was not written to the config file")
+	}
+
+	if _, err := runCommand(t, s, testPassword+"\n"+testPassword+"\n", "register", "alice"); err == nil {
+		t.Error("registering an existing user succeeded")
+	}
+	if _, err := runCommand(t, s, "short\nshort\n", "register", "bob"); err == nil {
+		t.Error("registering with a short password succeeded")
+	}
+	if _, err := runCommand(t, s, testPassword+"\nsomething else\n", "register", "bob"); err == nil {
+		t.Error("registering with mismatched passwords succeeded")
+	}
+	if _, err := runCommand(t, s, "", "register"); err == nil || !strings.Contains(err.Error(), "usage: gator register <username>") {
+		t.Errorf("register without a username returned %v, want a usage error", err)
+	}
+}
+
+func TestRegisterKeepsUserWhenConfigCannotBeWritten(t *testing.T) {
+	s := newTestState(t)
+	var hashes []string
+	s.db = tokenRecordingStore{s.db, &hashes}
+	home := filepath.Join(t.TempDir(), "not-a-directory")
+	if err := os.WriteFile(home, nil, 0o600); err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	t.Setenv("HOME", home)
+
+	_, err := runCommand(t, s, testPassword+"\n"+testPassword+"\n", "register", "alice")
+	if err == nil || !strings.Contains(err.Error(), "gator login alice") {
+		t.Fatalf("register without a writable config file returned %v, want a hint to log in", err)
+	}
+	if _, err := s.db.GetUserByName(context.Background(), "alice"); err != nil {
+		t.Errorf("getting alice after the config failed to save returned %v", err)
+	}
+	if len(hashes) != 1 {
+		t.Fatalf("register created %d tokens, want 1", len(hashes))
+	}
+	tokenParams := database.GetUserByApiTokenParams{TokenHash: hashes[0], ExpiresAt: time.Now()}
+	if _, err := s.db.GetUserByApiToken(context.Background(), tokenParams); err != sql.ErrNoRows {
+		t.Errorf("looking up the unsaved session token returned %v, want sql.ErrNoRows", err)
+	}
+}
+
+// failingTokenStore fails to store API tokens, inside transactions too.
+type failingTokenStore struct {
+	database.Store
+}
+
+func (f failingTokenStore) Tx(tx *sql.Tx) database.Store {
+	return failingTokenStore{f.Store.Tx(tx)}
+}
+
+func (failingTokenStore) CreateApiToken(context.Context, database.CreateApiTokenParams) (database.ApiToken, error) {
+	return database.ApiToken{}, errors.New("disk full")
+}
+
+func TestRegisterRollsBackWhenTokenCannotBeStored(t *testing.T) {
+	s := newTestState(t)
+	store := s.db
+	s.db = failingTokenStore{store}
+	if _, err := runCommand(t, s, testPassword+"\n"+testPassword+"\n", "register", "alice"); err == nil {
+		t.Fatal("register succeeded without storing a session token")
+	}
+	if _, err := store.GetUserByName(context.Background(), "alice"); err != sql.ErrNoRows {
+		t.Errorf("getting alice after a failed register returned %v, want sql.ErrNoRows", err)
+	}
+	s.db = store
+	register(t, s, "alice")
+}
+
+// tokenRecordingStore remembers the hash of every API token it creates.
+type tokenRecordingStore struct {
+	database.Store
+	hashes *[]string
+}
+
+func (r tokenRecordingStore) Tx(tx *sql.Tx) database.Store {
+	return tokenRecordingStore{r.Store.Tx(tx), r.hashes}
+}
+
+func (r tokenRecordingStore) CreateApiToken(ctx context.Context, arg database.CreateApiTokenParams) (database.ApiToken, error) {
+	*r.hashes = append(*r.hashes, arg.TokenHash)
+	return r.Store.CreateApiToken(ctx, arg)
+}
+
+func TestLoginRevokesTokenWhenConfigCannotBeWritten(t *testing.T) {
+	s := newTestState(t)
+	register(t, s, "alice")
+	var hashes []string
+	s.db = tokenRecordingStore{s.db, &hashes}
+	home := filepath.Join(t.TempDir(), "not-a-directory")
+	if err := os.WriteFile(home, nil, 0o600); err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+	t.Setenv("HOME", home)
+
+	if _, err := runCommand(t, s, testPassword+"\n", "login", "alice"); err == nil {
+		t.Fatal("login succeeded without a writable config file")
+	}
+	if len(hashes) != 1 {
+		t.Fatalf("login created %d tokens, want 1", len(hashes))
+	}
+	tokenParams := database.GetUserByApiTokenParams{TokenHash: hashes[0], ExpiresAt: time.Now()}
+	if _, err := s.db.GetUserByApiToken(context.Background(), tokenParams); err != sql.ErrNoRows {
+		t.Errorf("looking up the token of the failed login returned %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestState(t)
+	register(t, s, "alice")
+	register(t, s, "bob")
+
+	if _, err := runCommand(t, s, "wrong password\n", "login", "alice"); err == nil {
+		t.Error("logging in with the wrong password succeeded")
+	}
+	if s.cfg.CurrentUserName != "bob" {
+		t.Errorf("a failed login changed the current user to %q", s.cfg.CurrentUserName)
+	}
+	mustRun(t, s, testPassword+"\n", "login", "alice")
+	if s.cfg.CurrentUserName != "alice" {
+		t.Errorf("current user is %q after logging in as alice", s.cfg.CurrentUserName)
+	}
+	if _, err := runCommand(t, s, testPassword+"\n", "login", "carol"); err == nil {
+		t.Error("logging in as a missing user succeeded")
+	}
+}
+
+func TestLoginRefusesUserWithoutPassword(t *testing.T) {
+	s := newTestState(t)
+	legacy := storetest.CreateUser(t, s.db, "legacy")
+	register(t, s, "alice")
+	if _, err := runCommand(t, s, testPassword+"\n"+testPassword+"\n", "login", "legacy"); err == nil || !strings.Contains(err.Error(), "hashpassword") {
+		t.Errorf("logging in as a user without a password returned %v, want an error pointing to hashpassword", err)
+	}
+	if _, err := runCommand(t, s, testPassword+"\n"+testPassword+"\n"+testPassword+"\n", "setpassword", "legacy"); err == nil {
+		t.Error("alice set the password of another user")
+	}
+	user, err := s.db.GetUserByName(context.Background(), "legacy")
+	if err != nil {
+		t.Fatalf("getting user: %v", err)
+	}
+	if user.PasswordHash.Valid || s.cfg.CurrentUserName != "alice" {
+		t.Error("a login or setpassword attempt claimed the user without a password")
+	}
+
+	// An administrator stores a hash printed by hashpassword.
+	passwordHash := strings.TrimSpace(mustRun(t, s, "legacy password\nlegacy password\n", "hashpassword"))
+	passwordParams := database.SetUserPasswordParams{
+		PasswordHash: sql.NullString{String: passwordHash, Valid: true},
+		UpdatedAt:    time.Now(),
+		ID:           legacy.ID,
+	}
+	if err := s.db.SetUserPassword(context.Background(), passwordParams); err != nil {
+		t.Fatalf("setting password: %v", err)
+	}
+	mustRun(t, s, "legacy password\n", "login", "legacy")
+	if s.cfg.CurrentUserName != legacy.Name {
+		t.Errorf("current user is %q after an administrator set a password and logging in", s.cfg.CurrentUserName)
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	s := newTestState(t)
+	register(t, s, "alice")
+	output := strings.TrimSpace(mustRun(t, s, "", "apitoken"))
+	apiToken := output[strings.LastIndex(output, "\n")+1:]
+	if _, err := runCommand(t, s, "wrong password\nanother password\nanother password\n", "setpassword", "alice"); err == nil {
+		t.Error("setpassword succeeded with the wrong current password")
+	}
+	mustRun(t, s, testPassword+"\nanother password\nanother password\n", "setpassword", "alice")
+	if _, err := runCommand(t, s, "", "following"); err != nil {
+		t.Errorf("the session that changed the password stopped working: %v", err)
+	}
+	tokenParams := database.GetUserByApiTokenParams{TokenHash: auth.HashToken(apiToken), ExpiresAt: time.Now()}
+	if _, err := s.db.GetUserByApiToken(context.Background(), tokenParams); err != sql.ErrNoRows {
+		t.Errorf("looking up an API token issued before the change returned %v, want sql.ErrNoRows", err)
+	}
+	if _, err := runCommand(t, s, testPassword+"\n", "login", "alice"); err == nil {
+		t.Error("logging in with the old password succeeded")
+	}
+	mustRun(t, s, "another password\n", "login", "alice")
+}
+
+func TestAddFeed(t *testing.T) {
+	s := newTestState(t)
+	feedURL := newFeedServer(t)
+	if _, err := runCommand(t, s, "", "addfeed", "Blog", feedURL); err == nil {
+		t.Error("addfeed succeeded without a logged in user")
+	}
+	alice := register(t, s, "alice")
+	mustRun(t, s, "", "addfeed", "Blog", feedURL)
+
+	feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+	if err != nil {
+		t.Fatalf("getting the added feed: %v", err)
+	}
+	if feed.Name != "Blog" || feed.UserID != alice.ID {
+		t.Errorf("added feed is %q owned by %s, want Blog owned by alice", feed.Name, feed.UserID)
+	}
+	if urls := followedURLs(t, s, alice); len(urls) != 1 || urls[0] != feed.Url {
+		t.Errorf("alice follows %v, want only the added feed", urls)
+	}
+	if _, err := runCommand(t, s, "", "addfeed", "Blog again", feedURL); err == nil {
+		t.Error("adding a feed with an existing URL succeeded")
+	}
+}
+
+func TestFollowAndUnfollow(t *testing.T) {
+	s := newTestState(t)
+	feedURL := newFeedServer(t)
+	register(t, s, "alice")
+	mustRun(t, s, "", "addfeed", "Blog", feedURL)
+	bob := register(t, s, "bob")
+
+	output := mustRun(t, s, "", "follow", feedURL)
+	if !strings.Contains(output, "bob now follows Blog") {
+		t.Errorf("follow printed %q", output)
+	}
+	if urls := followedURLs(t, s, bob); len(urls) != 1 {
+		t.Errorf("bob follows %v after following the feed", urls)
+	}
+	if _, err := runCommand(t, s, "", "follow", feedURL); err == nil {
+		t.Error("following the same feed twice succeeded")
+	}
+	if _, err := runCommand(t, s, "", "follow", "https://example.com/missing"); err == nil {
+		t.Error("following a missing feed succeeded")
+	}
+
+	output = mustRun(t, s, "", "following", "--output", "csv")
+	if output != "name,url,category,unread\nBlog,"+feedURL+",,0\n" {
+		t.Errorf("following printed %q", output)
+	}
+
+	mustRun(t, s, "", "unfollow", feedURL)
+	if urls := followedURLs(t, s, bob); len(urls) != 0 {
+		t.Errorf("bob still follows %v after unfollowing", urls)
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	s := newTestState(t)
+	feedURL := newFeedServer(t)
+	alice := register(t, s, "alice")
+	mustRun(t, s, "", "addfeed", "Blog", feedURL)
+	feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+	if err != nil {
+		t.Fatalf("getting feed: %v", err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, title := range []string{"First", "Second", "Third"} {
+		storetest.UpsertPost(t, s.db, feed, title, title, base.Add(time.Duration(i)*time.Hour))
+	}
+	browse := func(args ...string) []postRow {
+		t.Helper()
+		output := mustRun(t, s, "", append([]string{"browse", "--output", "json"}, args...)...)
+		var posts []postRow
+		if err := json.Unmarshal([]byte(output), &posts); err != nil {
+			t.Fatalf("decoding browse output %q: %v", output, err)
+		}
+		return posts
+	}
+	titles := func(posts []postRow) string {
+		var titles []string
+		for _, post := range posts {
+			titles = append(titles, post.Title)
+		}
+		return strings.Join(titles, ",")
+	}
+
+	page := browse("--mark-read")
+	if got := titles(page); got != "Third,Second" {
+		t.Errorf("first page is %s, want Third,Second", got)
+	}
+	if got := titles(browse("--after", page[1].Cursor)); got != "First" {
+		t.Errorf("second page is %s, want First", got)
+	}
+	if got := titles(browse("--unread", "10")); got != "First" {
+		t.Errorf("unread posts after --mark-read are %s, want First", got)
+	}
+	if got := titles(browse("10", "--unread")); got != "First" {
+		t.Errorf("unread posts with the flag after the limit are %s, want First", got)
+	}
+	for _, keyword := range []string{"%", "_", "F_rst", `\`} {
+		if got := titles(browse("--keyword", keyword, "10")); got != "" {
+			t.Errorf("posts matching %q are %s, want none since wildcards match literally", keyword, got)
+		}
+	}
+	if got := titles(browse("--sort", "oldest", "--keyword", "ir", "10")); got != "First,Third" {
+		t.Errorf("oldest posts matching ir are %s, want First,Third", got)
+	}
+	if _, err := runCommand(t, s, "", "browse", "--sort", "sideways"); err == nil {
+		t.Error("browse accepted an unknown sort order")
+	}
+	for _, limit := range []string{"0", "-1", "101", "4294967297", "ten"} {
+		if _, err := runCommand(t, s, "", "browse", "--", limit); err == nil {
+			t.Errorf("browse accepted the limit %s", limit)
+		}
+	}
+
+	output := mustRun(t, s, "", "browse", "1")
+	if !strings.Contains(output, "Third") || !strings.Contains(output, "Next page: --after ") {
+		t.Errorf("browse table output is missing the post or the next page cursor:\n%s", output)
+	}
+	if !strings.Contains(output, "DESCRIPTION") {
+		t.Errorf("browse table output is missing the description column:\n%s", output)
+	}
+	if output := mustRun(t, s, "", "browse", "--since", "24h"); output != "There are no posts to display.\n" {
+		t.Errorf("browse without matching posts printed %q", output)
+	}
+	if urls := followedURLs(t, s, alice); len(urls) != 1 {
+		t.Errorf("browse changed alice's follows to %v", urls)
+	}
+}
+
+// secondReadFailingStore fails the second post it is asked to mark as read.
+type secondReadFailingStore struct {
+	database.Store
+	reads *int
+}
+
+func (f secondReadFailingStore) Tx(tx *sql.Tx) database.Store {
+	return secondReadFailingStore{f.Store.Tx(tx), f.reads}
+}
+
+func (f secondReadFailingStore) MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error {
+	if *f.reads++; *f.reads == 2 {
+		return errors.New("disk full")
+	}
+	return f.Store.MarkPostRead(ctx, arg)
+}
+
+func TestBrowseMarkReadIsAllOrNothing(t *testing.T) {
+	s := newTestState(t)
+	alice := register(t, s, "alice")
+	feed := storetest.CreateFeed(t, s.db, alice, "Blog", "https://example.com/feed")
+	storetest.Follow(t, s.db, alice, feed)
+	storetest.UpsertPost(t, s.db, feed, "1", "First", time.Now())
+	storetest.UpsertPost(t, s.db, feed, "2", "Second", time.Now())
+
+	store := s.db
+	s.db = secondReadFailingStore{store, new(int)}
+	if _, err := runCommand(t, s, "", "browse", "--mark-read", "10"); err == nil {
+		t.Fatal("browse --mark-read succeeded although marking a post failed")
+	}
+	posts, err := store.GetPostsForUser(context.Background(), database.GetPostsForUserParams{UserID: alice.ID, UnreadOnly: true, SortOrder: "newest", Limit: 10})
+	if err != nil {
+		t.Fatalf("getting posts: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Errorf("%d posts are unread after a failed --mark-read, want both", len(posts))
+	}
+}
+
+func TestListingOutputWithoutRows(t *testing.T) {
+	s := newTestState(t)
+	if output := mustRun(t, s, "", "users"); output != "There are no users.\n" {
+		t.Errorf("users printed %q for no users", output)
+	}
+	register(t, s, "alice")
+	for command, want := range map[string]string{
+		"feeds":     "There are no feeds.\n",
+		"following": "You are not following any feeds.\n",
+	} {
+		if output := mustRun(t, s, "", command); output != want {
+			t.Errorf("%s printed %q for no rows, want %q", command, output, want)
+		}
+		if output := mustRun(t, s, "", command, "--output", "json"); output != "[]\n" {
+			t.Errorf("%s --output json printed %q for no rows", command, output)
+		}
+	}
+}
+
+func TestListingOutput(t *testing.T) {
+	s := newTestState(t)
+	feedURL := newFeedServer(t)
+	register(t, s, "alice")
+	mustRun(t, s, "", "addfeed", "Blog", feedURL)
+
+	for _, args := range [][]string{
+		{"feederrors", "--output", "json"},
+		{"search", "--output", "json", "first"},
+		{"starred", "--output", "json"},
+	} {
+		if output := mustRun(t, s, "", args...); output != "[]\n" {
+			t.Errorf("%s --output json printed %q for no rows", args[0], output)
+		}
+	}
+	if output := mustRun(t, s, "", "starred"); output != "There are no starred posts.\n" {
+		t.Errorf("starred printed %q for no stars", output)
+	}
+
+	if err := scrapeFeedsConcurrently(s, 1, time.Minute); err != nil {
+		t.Fatalf("scraping: %v", err)
+	}
+	var results []searchRow
+	output := mustRun(t, s, "", "search", "--output", "json", "first")
+	if err := json.Unmarshal([]byte(output), &results); err != nil {
+		t.Fatalf("decoding search output %q: %v", output, err)
+	}
+	if len(results) != 1 || results[0].Feed != "Blog" || results[0].URL != "https://example.com/1" {
+		t.Fatalf("search returned %+v, want the scraped post", results)
+	}
+	mustRun(t, s, "", "star", results[0].ID.String())
+	output = mustRun(t, s, "", "starred", "--output", "csv")
+	if !strings.HasPrefix(output, "id,post_id,feed,title,url,description,published_at,starred_at\n") || !strings.Contains(output, ",Blog,Hello,https://example.com/1,The first post,") {
+		t.Errorf("starred printed %q", output)
+	}
+}
+
+func TestSearchKeepsArgumentsStartingWithDash(t *testing.T) {
+	s := newTestState(t)
+	alice := register(t, s, "alice")
+	feed := storetest.CreateFeed(t, s.db, alice, "Blog", "https://example.com/feed")
+	storetest.Follow(t, s.db, alice, feed)
+	storetest.UpsertPost(t, s.db, feed, "release", "pgvector release", time.Now())
+	storetest.UpsertPost(t, s.db, feed, "draft", "pgvector draft", time.Now())
+
+	var results []searchRow
+	output := mustRun(t, s, "", "search", "--output", "json", "pgvector", "-draft")
+	if err := json.Unmarshal([]byte(output), &results); err != nil {
+		t.Fatalf("decoding search output %q: %v", output, err)
+	}
+	if len(results) != 1 || results[0].Title != "pgvector release" {
+		t.Errorf("search pgvector -draft returned %+v, want only the release", results)
+	}
+	if _, err := runCommand(t, s, "", "browse", "5", "--unread"); err != nil {
+		t.Errorf("browse with a flag after its argument failed: %v", err)
+	}
+}
+
+const testFeed = `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+<title>Blog</title>
+<link>https://example.com</link>
+<description>A blog</description>
+<item>
+<title>%s</title>
+<link>https://example.com/1</link>
+<guid>1</guid>
+<pubDate>Mon, 01 Jan 2024 10:00:00 +0000</pubDate>
+<description>The first post</description>
+</item>
+</channel>
+</rss>`
+
+// newFeedServer serves testFeed and returns its URL.
+func newFeedServer(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		io.WriteString(w, strings.Replace(testFeed, "%s", "Hello", 1))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/feed"
+}
+
+func TestAddFeedDiscovery(t *testing.T) {
+	s := newTestState(t)
+	alice := register(t, s, "alice")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, `<!DOCTYPE html>
+<html><head>
+<link rel="alternate" type="application/rss+xml" title="Posts" href="posts.xml">
+<link rel="alternate" type="application/rss+xml" title="Comments" href="/comments.xml">
+</head><body>Hello</body></html>`)
+	})
+	mux.HandleFunc("/blog/posts.xml", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Replace(testFeed, "%s", "Hello", 1))
+	})
+	mux.HandleFunc("/comments.xml", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Replace(testFeed, "%s", "A comment", 1))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	output, err := runCommand(t, s, "3\n", "addfeed", "Blog", server.URL+"/blog/")
+	if err == nil {
+		t.Error("addfeed accepted a choice outside the list")
+	}
+	if !strings.Contains(output, "1. Blog - "+server.URL+"/blog/posts.xml") || !strings.Contains(output, "2. Blog - "+server.URL+"/comments.xml") {
+		t.Errorf("addfeed did not list the discovered feeds:\n%s", output)
+	}
+	if urls := followedURLs(t, s, alice); len(urls) != 0 {
+		t.Errorf("alice follows %v after a failed addfeed", urls)
+	}
+
+	mustRun(t, s, "2\n", "addfeed", "Blog comments", server.URL+"/blog/")
+	if urls := followedURLs(t, s, alice); len(urls) != 1 || urls[0] != server.URL+"/comments.xml" {
+		t.Errorf("alice follows %v, want the chosen comments feed", urls)
+	}
+
+	if _, err := runCommand(t, s, "", "addfeed", "Nothing", server.URL+"/missing"); err == nil {
+		t.Error("addfeed succeeded for a page that does not exist")
+	}
+}
+
+// failingPostStore fails to save any post, inside transactions too.
+type failingPostStore struct {
+	database.Store
+}
+
+func (f failingPostStore) Tx(tx *sql.Tx) database.Store {
+	return failingPostStore{f.Store.Tx(tx)}
+}
+
+func (failingPostStore) UpsertPost(context.Context, database.UpsertPostParams) (database.UpsertPostRow, error) {
+	return database.UpsertPostRow{}, errors.New("disk full")
+}
+
+func TestScrapeFeeds(t *testing.T) {
+	s := newTestState(t)
+	alice := register(t, s, "alice")
+	var mu sync.Mutex
+	title, status := "Hello", http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		etag := `"` + title + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		io.WriteString(w, strings.Replace(testFeed, "%s", title, 1))
+	}))
+	defer server.Close()
+	setFeed := func(newTitle string, newStatus int) {
+		mu.Lock()
+		defer mu.Unlock()
+		title, status = newTitle, newStatus
+	}
+	mustRun(t, s, "", "addfeed", "Blog", server.URL)
+	ctx := context.Background()
+	scrape := func() database.Feed {
+		t.Helper()
+		var err error
+		captureStdout(t, func() { err = scrapeFeedsConcurrently(s, 1, time.Minute) })
+		if err != nil {
+			t.Fatalf("scrapeFeedsConcurrently: %v", err)
+		}
+		feed, err := s.db.GetFeedByUrl(ctx, server.URL)
+		if err != nil {
+			t.Fatalf("getting feed: %v", err)
+		}
+		// Make the feed due again for the next scrape.
+		err = s.db.ScheduleNextFetch(ctx, database.ScheduleNextFetchParams{
+			NextFetchAt: sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true},
+			ID:          feed.ID,
+		})
+		if err != nil {
+			t.Fatalf("rescheduling feed: %v", err)
+		}
+		return feed
+	}
+	posts := func() []database.GetPostsForUserRow {
+		t.Helper()
+		posts, err := s.db.GetPostsForUser(ctx, database.GetPostsForUserParams{UserID: alice.ID, SortOrder: "newest", Limit: 10})
+		if err != nil {
+			t.Fatalf("getting posts: %v", err)
+		}
+		return posts
+	}
+
+	store := s.db
+	s.db = failingPostStore{store}
+	feed := scrape()
+	s.db = store
+	if feed.Etag.Valid || feed.LastStatusCode.Int32 != http.StatusOK {
+		t.Errorf("feed has etag %q and status %d after its posts failed to save, want no etag", feed.Etag.String, feed.LastStatusCode.Int32)
+	}
+
+	feed = scrape()
+	if got := posts(); len(got) != 1 || got[0].Title != "Hello" || got[0].Updated {
+		t.Fatalf("posts after the first scrape are %+v, want one new Hello post", got)
+	}
+	if feed.Etag.String != `"Hello"` || feed.LastStatusCode.Int32 != http.StatusOK {
+		t.Errorf("feed has etag %q and status %d after a successful fetch", feed.Etag.String, feed.LastStatusCode.Int32)
+	}
+
+	feed = scrape()
+	if feed.LastStatusCode.Int32 != http.StatusNotModified {
+		t.Errorf("feed has status %d after an unchanged fetch, want 304", feed.LastStatusCode.Int32)
+	}
+
+	setFeed("Hello again", http.StatusOK)
+	scrape()
+	if got := posts(); len(got) != 1 || got[0].Title != "Hello again" || !got[0].Updated {
+		t.Errorf("posts after an upstream edit are %+v, want the edited post marked as updated", got)
+	}
+
+	setFeed("Hello again", http.StatusInternalServerError)
+	feed = scrape()
+	if feed.ConsecutiveErrors != 1 || feed.LastStatusCode.Int32 != http.StatusInternalServerError {
+		t.Errorf("feed has %d errors and status %d after a failed fetch", feed.ConsecutiveErrors, feed.LastStatusCode.Int32)
+	}
+
+	captureStdout(t, func() {
+		for range defaultMaxFeedFailures {
+			if err := scrapeFeedsConcurrently(s, 1, time.Minute); err != nil {
+				t.Errorf("scrapeFeedsConcurrently: %v", err)
+			}
+			s.db.ScheduleNextFetch(ctx, database.ScheduleNextFetchParams{
+				NextFetchAt: sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true},
+				ID:          feed.ID,
+			})
+		}
+	})
+	feed, err := s.db.GetFeedByUrl(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("getting feed: %v", err)
+	}
+	if !feed.DisabledAt.Valid {
+		t.Errorf("feed is still enabled after %d consecutive failures", feed.ConsecutiveErrors)
+	}
+}
+
+func TestScrapeFeedsConcurrentlyDrainsDueFeeds(t *testing.T) {
+	s := newTestState(t)
+	alice := register(t, s, "alice")
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, strings.Replace(strings.Replace(testFeed, "%s", "Post"+r.URL.Path, 1), "<guid>1</guid>", "<guid>"+r.URL.Path+"</guid>", 1))
+	}))
+	defer server.Close()
+	paths := []string{"/a", "/b", "/c", "/d", "/e"}
+	for _, path := range paths {
+		mustRun(t, s, "", "addfeed", "Feed "+path, server.URL+path)
+	}
+	// addfeed rejects feeds that do not load, so this one is created directly.
+	storetest.CreateFeed(t, s.db, alice, "Broken", server.URL+"/broken")
+	mu.Lock()
+	clear(requests)
+	mu.Unlock()
+
+	var err error
+	captureStdout(t, func() { err = scrapeFeedsConcurrently(s, 2, time.Minute) })
+	if err != nil {
+		t.Errorf("scrapeFeedsConcurrently: %v", err)
+	}
+	for _, path := range append(paths, "/broken") {
+		if requests[path] != 1 {
+			t.Errorf("%s was fetched %d times in one round, want once", path, requests[path])
+		}
+	}
+	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{UserID: alice.ID, SortOrder: "newest", Limit: 10})
+	if err != nil {
+		t.Fatalf("getting posts: %v", err)
+	}
+	if len(posts) != len(paths) {
+		t.Errorf("got %d posts after one round, want one from each of the %d feeds", len(posts), len(paths))
+	}
+}
+
+func TestScrapeFeedsConcurrentlyFetchesEachFeedOncePerRound(t *testing.T) {
+	s := newTestState(t)
+	register(t, s, "alice")
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		io.WriteString(w, strings.Replace(testFeed, "%s", "Hello", 1))
+	}))
+	defer server.Close()
+	mustRun(t, s, "", "addfeed", "Blog", server.URL)
+	mu.Lock()
+	requests = 0
+	mu.Unlock()
+
+	// With an interval this short the feed is due again as soon as its
+	// fetch finishes.
+	var err error
+	captureStdout(t, func() { err = scrapeFeedsConcurrently(s, 2, time.Nanosecond) })
+	if err != nil {
+		t.Errorf("scrapeFeedsConcurrently: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("the feed was fetched %d times in one round, want once", requests)
+	}
+}
+
+func TestLeaseNextFeedOutlastsTheRequest(t *testing.T) {
+	s := newTestState(t)
+	alice := register(t, s, "alice")
+	storetest.CreateFeed(t, s.db, alice, "Blog", "https://example.com/feed")
+	start := time.Now()
+	feed, err := leaseNextFeed(s, time.Second, start)
+	if err != nil {
+		t.Fatalf("leaseNextFeed: %v", err)
+	}
+	if feed.NextFetchAt.Time.Before(start.Add(fetch.RequestTimeout)) {
+		t.Errorf("feed is leased until %v, want longer than a request may take", feed.NextFetchAt.Time)
+	}
+}
+
+func TestSetInterval(t *testing.T) {
+	s := newTestState(t)
+	feedURL := newFeedServer(t)
+	register(t, s, "alice")
+	mustRun(t, s, "", "addfeed", "Blog", feedURL)
+	interval := func() sql.NullInt32 {
+		t.Helper()
+		feed, err := s.db.GetFeedByUrl(context.Background(), feedURL)
+		if err != nil {
+			t.Fatalf("getting feed: %v", err)
+		}
+		return feed.FetchIntervalSeconds
+	}
+
+	mustRun(t, s, "", "setinterval", feedURL, "90s")
+	if got := interval(); got != (sql.NullInt32{Int32: 90, Valid: true}) {
+		t.Errorf("interval is %+v after setting 90s", got)
+	}
+	for _, value := range []string{"500ms", "-1m", "600000h"} {
+		if _, err := runCommand(t, s, "", "setinterval", feedURL, value); err == nil {
+			t.Errorf("setinterval accepted %s", value)
+		}
+	}
+	if got := interval(); got.Int32 != 90 {
+		t.Errorf("a rejected interval changed the interval to %+v", got)
+	}
+	mustRun(t, s, "", "setinterval", feedURL, "0")
+	if got := interval(); got.Valid {
+		t.Errorf("interval is %+v after resetting it, want the default", got)
+	}
+}
+
+func TestScrapeFeedsKeepsRefreshHintsOnNotModified(t *testing.T) {
+	s := newTestState(t)
+	register(t, s, "alice")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, strings.Replace(strings.Replace(testFeed, "%s", "Hello", 1), "<title>Blog</title>", "<title>Blog</title><ttl>120</ttl>", 1))
+	}))
+	defer server.Close()
+	mustRun(t, s, "", "addfeed", "Blog", server.URL)
+	ctx := context.Background()
+
+	for _, wantStatus := range []int32{http.StatusOK, http.StatusNotModified} {
+		start := time.Now()
+		captureStdout(t, func() {
+			if err := scrapeFeedsConcurrently(s, 1, time.Minute); err != nil {
+				t.Errorf("scrapeFeedsConcurrently: %v", err)
+			}
+		})
+		feed, err := s.db.GetFeedByUrl(ctx, server.URL)
+		if err != nil {
+			t.Fatalf("getting feed: %v", err)
+		}
+		if feed.LastStatusCode.Int32 != wantStatus {
+			t.Fatalf("feed has status %d, want %d", feed.LastStatusCode.Int32, wantStatus)
+		}
+		if next := feed.NextFetchAt.Time; next.Before(start.Add(2 * time.Hour)) {
+			t.Errorf("after a %d response the next fetch is at %v, want the feed's 2h ttl respected", wantStatus, next)
+		}
+		err = s.db.ScheduleNextFetch(ctx, database.ScheduleNextFetchParams{
+			NextFetchAt: sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true},
+			ID:          feed.ID,
+		})
+		if err != nil {
+			t.Fatalf("rescheduling feed: %v", err)
+		}
+	}
+}
+
+func TestSavePostsWithoutGUID(t *testing.T) {
+	s := newTestState(t)
+	alice := register(t, s, "alice")
+	feed := storetest.CreateFeed(t, s.db, alice, "Blog", "https://example.com/feed")
+	storetest.Follow(t, s.db, alice, feed)
+	item := func(title, link string) fetch.RSSItem {
+		return fetch.RSSItem{Title: title, Link: link, PubDate: "Mon, 01 Jan 2024 10:00:00 +0000"}
+	}
+	titles := func() map[string]bool {
+		t.Helper()
+		posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{UserID: alice.ID, SortOrder: "newest", Limit: 10})
+		if err != nil {
+			t.Fatalf("getting posts: %v", err)
+		}
+		titles := make(map[string]bool)
+		for _, post := range posts {
+			titles[post.Title] = post.Updated
+		}
+		return titles
+	}
+
+	save := func(items ...fetch.RSSItem) {
+		t.Helper()
+		var failed int
+		captureStdout(t, func() { failed = savePosts(s, feed.ID, items) })
+		if failed != 0 {
+			t.Fatalf("%d posts failed to save", failed)
+		}
+	}
+	save(item("Hello", "https://example.com/1"), item("One", "https://example.com/shared"), item("Two", "https://example.com/shared"))
+	if got := titles(); len(got) != 3 {
+		t.Fatalf("got posts %v, want items sharing a link kept apart", got)
+	}
+
+	save(item("Hello, corrected", "https://example.com/1"), item("One", "https://example.com/shared"), item("Two", "https://example.com/shared"))
+	got := titles()
+	if updated, ok := got["Hello, corrected"]; len(got) != 3 || !ok || !updated {
+		t.Errorf("got posts %v after a title correction, want the post with the same link updated", got)
+	}
+}
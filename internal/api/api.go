@@ -0,0 +1,176 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/panaiotuzunov/gator/internal/auth"
+	"github.com/panaiotuzunov/gator/internal/database"
+	"github.com/panaiotuzunov/gator/internal/storage"
+)
+
+const (
+	defaultPostLimit = 20
+	maxPostLimit     = 100
+	maxBodyBytes     = 1 << 20
+	tokenLifetime    = 30 * 24 * time.Hour
+)
+
+type authedHandler func(http.ResponseWriter, *http.Request, database.User)
+
+type Server struct {
+	db   database.Store
+	conn *sql.DB
+}
+
+func NewServer(db database.Store, conn *sql.DB) *Server {
+	return &Server{db: db, conn: conn}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/users", s.handleCreateUser)
+	mux.HandleFunc("POST /api/login", s.handleLogin)
+	mux.HandleFunc("GET /api/users", s.middlewareAuth(s.handleListUsers))
+	mux.HandleFunc("GET /api/users/{userID}", s.middlewareAuth(s.handleGetUser))
+	mux.HandleFunc("DELETE /api/users/{userID}", s.middlewareAuth(s.handleDeleteUser))
+	mux.HandleFunc("GET /api/feeds", s.middlewareAuth(s.handleListFeeds))
+	mux.HandleFunc("POST /api/feeds", s.middlewareAuth(s.handleCreateFeed))
+	mux.HandleFunc("DELETE /api/feeds/{feedID}", s.middlewareAuth(s.handleDeleteFeed))
+	mux.HandleFunc("GET /api/users/{userID}/follows", s.middlewareAuth(s.handleListFollows))
+	mux.HandleFunc("POST /api/users/{userID}/follows", s.middlewareAuth(s.handleCreateFollow))
+	mux.HandleFunc("DELETE /api/users/{userID}/follows/{feedID}", s.middlewareAuth(s.handleDeleteFollow))
+	mux.HandleFunc("GET /api/users/{userID}/posts", s.middlewareAuth(s.handleListPosts))
+	return mux
+}
+
+// middlewareAuth resolves the bearer token to its user. Tokens are stored
+// hashed, so the presented token is hashed before the lookup.
+func (s *Server) middlewareAuth(handler authedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		tokenHash := auth.HashToken(token)
+		user, err := s.db.GetUserByApiToken(r.Context(), database.GetUserByApiTokenParams{
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now(),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "invalid or expired token")
+			return
+		} else if err != nil {
+			respondWithDBError(w, "error validating token", err)
+			return
+		}
+		err = s.db.TouchApiToken(r.Context(), database.TouchApiTokenParams{
+			LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			TokenHash:  tokenHash,
+		})
+		if err != nil {
+			respondWithDBError(w, "error updating token", err)
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+// pathSelf parses the userID path value and rejects requests for any user
+// other than the authenticated one.
+func pathSelf(w http.ResponseWriter, r *http.Request, user database.User) (uuid.UUID, bool) {
+	userID, ok := pathUUID(w, r, "userID")
+	if !ok {
+		return uuid.Nil, false
+	}
+	if userID != user.ID {
+		respondWithError(w, http.StatusForbidden, "forbidden")
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("error marshaling response - %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error marshaling response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+func respondWithError(w http.ResponseWriter, code int, msg string) {
+	type errorResponse struct {
+		Error string `json:"error"`
+	}
+	data, _ := json.Marshal(errorResponse{Error: msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// respondWithDBError maps database errors to HTTP status codes: missing rows
+// become 404 and unique constraint violations become 409. Any other error is
+// logged rather than sent, since the driver's message may describe the schema
+// or the query.
+func respondWithDBError(w http.ResponseWriter, msg string, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, msg+" - not found")
+		return
+	}
+	if storage.IsUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, msg+" - already exists")
+		return
+	}
+	log.Printf("%s - %v", msg, err)
+	respondWithError(w, http.StatusInternalServerError, msg)
+}
+
+// decodeJSON decodes the request body into dst. Bodies over maxBodyBytes are
+// rejected with 413.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return false
+		}
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request body - %v", err))
+		return false
+	}
+	return true
+}
+
+func pathUUID(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue(name))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s", name))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return n, nil
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/panaiotuzunov/gator/internal/database"
+	"github.com/panaiotuzunov/gator/internal/storage"
+	"github.com/panaiotuzunov/gator/internal/storetest"
+)
+
+const testPassword = "correct horse"
+
+// testServer wraps the API handler of a fresh in-memory database.
+type testServer struct {
+	db      *storage.DB
+	handler http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	db := storetest.Open(t)
+	return &testServer{db: db, handler: NewServer(db.Store, db.Conn).Handler()}
+}
+
+// do sends a request with token as its bearer token, if there is one, and
+// body encoded as JSON, unless it is a string.
+func (ts *testServer) do(t *testing.T, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(body)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encoding request body: %v", err)
+		}
+		reader = strings.NewReader(string(data))
+	}
+	r := httptest.NewRequest(method, path, reader)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	return w
+}
+
+// login creates a user with testPassword and returns the user and a token.
+func (ts *testServer) login(t *testing.T, name string) (userResponse, string) {
+	t.Helper()
+	credentials := map[string]string{"name": name, "password": testPassword}
+	if w := ts.do(t, "POST", "/api/users", "", credentials); w.Code != http.StatusCreated {
+		t.Fatalf("creating user %s returned %d: %s", name, w.Code, w.Body)
+	}
+	w := ts.do(t, "POST", "/api/login", "", credentials)
+	if w.Code != http.StatusOK {
+		t.Fatalf("logging in as %s returned %d: %s", name, w.Code, w.Body)
+	}
+	var response tokenResponse
+	decode(t, w, &response)
+	return response.User, response.Token
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, dst any) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), dst); err != nil {
+		t.Fatalf("decoding response %q: %v", w.Body, err)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.login(t, "alice")
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"unknown token", "not-a-token", http.StatusUnauthorized},
+		{"valid token", token, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := ts.do(t, "GET", "/api/users", tt.token, nil); w.Code != tt.want {
+				t.Errorf("GET /api/users returned %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	if w := ts.do(t, "POST", "/api/login", "", map[string]string{"name": "alice", "password": "wrong password"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("login with a wrong password returned %d, want 401", w.Code)
+	}
+}
+
+func TestPathSelf(t *testing.T) {
+	ts := newTestServer(t)
+	alice, aliceToken := ts.login(t, "alice")
+	bob, _ := ts.login(t, "bob")
+
+	for _, request := range []struct{ method, path string }{
+		{"GET", "/api/users/" + bob.ID.String() + "/follows"},
+		{"GET", "/api/users/" + bob.ID.String() + "/posts"},
+		{"DELETE", "/api/users/" + bob.ID.String()},
+	} {
+		if w := ts.do(t, request.method, request.path, aliceToken, nil); w.Code != http.StatusForbidden {
+			t.Errorf("%s %s as alice returned %d, want 403", request.method, request.path, w.Code)
+		}
+	}
+	if w := ts.do(t, "GET", "/api/users/"+alice.ID.String()+"/follows", aliceToken, nil); w.Code != http.StatusOK {
+		t.Errorf("listing alice's own follows returned %d, want 200", w.Code)
+	}
+	if w := ts.do(t, "GET", "/api/users/not-a-uuid/follows", aliceToken, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("an invalid user ID returned %d, want 400", w.Code)
+	}
+}
+
+// failingFollowStore fails to create feed follows, inside transactions too.
+type failingFollowStore struct {
+	database.Store
+}
+
+func (f failingFollowStore) Tx(tx *sql.Tx) database.Store {
+	return failingFollowStore{f.Store.Tx(tx)}
+}
+
+func (failingFollowStore) CreateFeedFollow(context.Context, database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	return database.CreateFeedFollowRow{}, errors.New("disk full")
+}
+
+func TestCreateFeed(t *testing.T) {
+	ts := newTestServer(t)
+	alice, token := ts.login(t, "alice")
+	follows := func() []followResponse {
+		t.Helper()
+		w := ts.do(t, "GET", "/api/users/"+alice.ID.String()+"/follows", token, nil)
+		var response []followResponse
+		decode(t, w, &response)
+		return response
+	}
+
+	w := ts.do(t, "POST", "/api/feeds", token, map[string]string{"name": "Blog", "url": "https://example.com/feed"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating a feed returned %d: %s", w.Code, w.Body)
+	}
+	if got := follows(); len(got) != 1 || got[0].FeedName != "Blog" {
+		t.Errorf("alice follows %+v after adding a feed, want the new feed", got)
+	}
+	if w := ts.do(t, "POST", "/api/feeds", token, map[string]string{"name": "Again", "url": "https://example.com/feed"}); w.Code != http.StatusConflict {
+		t.Errorf("adding the same URL twice returned %d, want 409", w.Code)
+	}
+	if w := ts.do(t, "POST", "/api/feeds", token, map[string]string{"name": "Blog"}); w.Code != http.StatusBadRequest {
+		t.Errorf("adding a feed without a URL returned %d, want 400", w.Code)
+	}
+
+	ts.handler = NewServer(failingFollowStore{ts.db.Store}, ts.db.Conn).Handler()
+	logOutput := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(logOutput) })
+	w = ts.do(t, "POST", "/api/feeds", token, map[string]string{"name": "Other", "url": "https://example.com/other"})
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("creating a feed whose follow fails returned %d, want 500", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "disk full") {
+		t.Errorf("the 500 response %q contains the database error", w.Body)
+	}
+	if _, err := ts.db.Store.GetFeedByUrl(context.Background(), "https://example.com/other"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("the feed was kept after its follow failed: %v", err)
+	}
+}
+
+func TestListPostsPaging(t *testing.T) {
+	ts := newTestServer(t)
+	alice, token := ts.login(t, "alice")
+	user, err := ts.db.Store.GetUser(context.Background(), alice.ID)
+	if err != nil {
+		t.Fatalf("getting alice: %v", err)
+	}
+	feed := storetest.CreateFeed(t, ts.db.Store, user, "Blog", "https://example.com/feed")
+	storetest.Follow(t, ts.db.Store, user, feed)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, guid := range []string{"1", "2", "3"} {
+		storetest.UpsertPost(t, ts.db.Store, feed, guid, "Post "+guid, start.Add(time.Duration(i)*time.Hour))
+	}
+
+	path := "/api/users/" + alice.ID.String() + "/posts?limit=2"
+	var titles []string
+	for page := 0; ; page++ {
+		if page == 3 {
+			t.Fatal("next_cursor did not run out")
+		}
+		w := ts.do(t, "GET", path, token, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s returned %d: %s", path, w.Code, w.Body)
+		}
+		var response postsPageResponse
+		decode(t, w, &response)
+		for _, post := range response.Posts {
+			titles = append(titles, post.Title)
+		}
+		if response.NextCursor == nil {
+			break
+		}
+		path = "/api/users/" + alice.ID.String() + "/posts?limit=2&after=" + *response.NextCursor
+	}
+	if strings.Join(titles, ", ") != "Post 3, Post 2, Post 1" {
+		t.Errorf("paging returned %v, want every post once, newest first", titles)
+	}
+
+	if w := ts.do(t, "GET", "/api/users/"+alice.ID.String()+"/posts?after=nonsense", token, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("an invalid cursor returned %d, want 400", w.Code)
+	}
+}
+
+func TestDecodeJSONLimitsTheBody(t *testing.T) {
+	ts := newTestServer(t)
+	body := `{"name": "alice", "password": "` + strings.Repeat("x", maxBodyBytes) + `"}`
+	if w := ts.do(t, "POST", "/api/users", "", body); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("an oversized body returned %d, want 413", w.Code)
+	}
+	if w := ts.do(t, "POST", "/api/users", "", `{"name": "alice", "admin": true}`); w.Code != http.StatusBadRequest {
+		t.Errorf("a body with an unknown field returned %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/panaiotuzunov/gator/internal/database"
+)
+
+type feedResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	UserID        uuid.UUID  `json:"user_id"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	DisabledAt    *time.Time `json:"disabled_at"`
+}
+
+func newFeedResponse(feed database.Feed) feedResponse {
+	response := feedResponse{
+		ID:        feed.ID,
+		CreatedAt: feed.CreatedAt,
+		UpdatedAt: feed.UpdatedAt,
+		Name:      feed.Name,
+		URL:       feed.Url,
+		UserID:    feed.UserID,
+	}
+	if feed.LastFetchedAt.Valid {
+		response.LastFetchedAt = &feed.LastFetchedAt.Time
+	}
+	if feed.DisabledAt.Valid {
+		response.DisabledAt = &feed.DisabledAt.Time
+	}
+	return response
+}
+
+func (s *Server) handleListFeeds(w http.ResponseWriter, r *http.Request, _ database.User) {
+	feeds, err := s.db.GetAllFeeds(r.Context())
+	if err != nil {
+		respondWithDBError(w, "error getting feeds", err)
+		return
+	}
+	response := make([]feedResponse, 0, len(feeds))
+	for _, feed := range feeds {
+		response = append(response, newFeedResponse(feed))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handleCreateFeed mirrors the addfeed command: the feed is created and the
+// user who added it follows it.
+func (s *Server) handleCreateFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	var params struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+	params.Name = strings.TrimSpace(params.Name)
+	params.URL = strings.TrimSpace(params.URL)
+	if params.Name == "" || params.URL == "" {
+		respondWithError(w, http.StatusBadRequest, "name and url are required")
+		return
+	}
+	tx, err := s.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithDBError(w, "error starting transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := s.db.Tx(tx)
+	feed, err := qtx.CreateFeed(r.Context(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Name:      params.Name,
+		Url:       params.URL,
+		UserID:    user.ID,
+	})
+	if err != nil {
+		respondWithDBError(w, "error creating feed", err)
+		return
+	}
+	_, err = qtx.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+	})
+	if err != nil {
+		respondWithDBError(w, "error creating feed follow", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithDBError(w, "error committing transaction", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, newFeedResponse(feed))
+}
+
+// handleDeleteFeed only deletes feeds the authenticated user added. Feeds
+// added by someone else are reported as not found.
+func (s *Server) handleDeleteFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedID, ok := pathUUID(w, r, "feedID")
+	if !ok {
+		return
+	}
+	deleted, err := s.db.DeleteFeed(r.Context(), database.DeleteFeedParams{
+		ID:     feedID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithDBError(w, "error deleting feed", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "feed not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
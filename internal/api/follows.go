@@ -0,0 +1,106 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/panaiotuzunov/gator/internal/database"
+)
+
+type followResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	FeedID    uuid.UUID `json:"feed_id"`
+	FeedName  string    `json:"feed_name"`
+	FeedURL   string    `json:"feed_url,omitempty"`
+	Category  string    `json:"category,omitempty"`
+}
+
+func (s *Server) handleListFollows(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, ok := pathSelf(w, r, user)
+	if !ok {
+		return
+	}
+	feedFollows, err := s.db.GetFeedFollowsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithDBError(w, "error getting feed follows", err)
+		return
+	}
+	response := make([]followResponse, 0, len(feedFollows))
+	for _, feedFollow := range feedFollows {
+		response = append(response, followResponse{
+			ID:        feedFollow.ID,
+			CreatedAt: feedFollow.CreatedAt,
+			UserID:    feedFollow.UserID,
+			FeedID:    feedFollow.FeedID,
+			FeedName:  feedFollow.FeedName,
+			FeedURL:   feedFollow.FeedUrl,
+			Category:  feedFollow.Category.String,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleCreateFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, ok := pathSelf(w, r, user)
+	if !ok {
+		return
+	}
+	var params struct {
+		FeedURL  string `json:"feed_url"`
+		Category string `json:"category"`
+	}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+	feed, err := s.db.GetFeedByUrl(r.Context(), strings.TrimSpace(params.FeedURL))
+	if err != nil {
+		respondWithDBError(w, "error getting feed", err)
+		return
+	}
+	feedFollow, err := s.db.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    userID,
+		FeedID:    feed.ID,
+		Category:  sql.NullString{String: params.Category, Valid: params.Category != ""},
+	})
+	if err != nil {
+		respondWithDBError(w, "error creating feed follow", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, followResponse{
+		ID:        feedFollow.ID,
+		CreatedAt: feedFollow.CreatedAt,
+		UserID:    feedFollow.UserID,
+		FeedID:    feedFollow.FeedID,
+		FeedName:  feedFollow.FeedName,
+		FeedURL:   feed.Url,
+		Category:  feedFollow.Category.String,
+	})
+}
+
+func (s *Server) handleDeleteFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, ok := pathSelf(w, r, user)
+	if !ok {
+		return
+	}
+	feedID, ok := pathUUID(w, r, "feedID")
+	if !ok {
+		return
+	}
+	err := s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		UserID: userID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		respondWithDBError(w, "error deleting feed follow", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/panaiotuzunov/gator/internal/cursor"
+	"github.com/panaiotuzunov/gator/internal/database"
+)
+
+type postResponse struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	Updated     bool      `json:"updated"`
+	Read        bool      `json:"read"`
+	Starred     bool      `json:"starred"`
+}
+
+type postsPageResponse struct {
+	Posts      []postResponse `json:"posts"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+// handleListPosts pages through GetPostsForUser with limit/after query
+// parameters, optionally only unread posts with unread=true. next_cursor is
+// passed back as after to get the following page and is null once the last
+// page has been returned.
+func (s *Server) handleListPosts(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, ok := pathSelf(w, r, user)
+	if !ok {
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultPostLimit)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit = min(max(limit, 1), maxPostLimit)
+	params := database.GetPostsForUserParams{
+		UserID:     userID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		SortOrder:  "newest",
+		Limit:      int32(limit),
+	}
+	if after := r.URL.Query().Get("after"); after != "" {
+		publishedAt, id, err := cursor.Decode(after)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		params.AfterPublishedAt = sql.NullTime{Time: publishedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	posts, err := s.db.GetPostsForUser(r.Context(), params)
+	if err != nil {
+		respondWithDBError(w, "error getting posts", err)
+		return
+	}
+	response := postsPageResponse{Posts: make([]postResponse, 0, len(posts))}
+	for _, post := range posts {
+		response.Posts = append(response.Posts, postResponse{
+			ID:          post.ID,
+			CreatedAt:   post.CreatedAt,
+			UpdatedAt:   post.UpdatedAt,
+			Title:       post.Title,
+			URL:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			Updated:     post.Updated,
+			Read:        post.IsRead,
+			Starred:     post.Starred,
+		})
+	}
+	if len(posts) == limit {
+		last := posts[len(posts)-1]
+		nextCursor := cursor.Encode(last.PublishedAt, last.ID)
+		response.NextCursor = &nextCursor
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
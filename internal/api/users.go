@@ -0,0 +1,155 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/panaiotuzunov/gator/internal/auth"
+	"github.com/panaiotuzunov/gator/internal/database"
+)
+
+type userResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+func newUserResponse(user database.User) userResponse {
+	return userResponse{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Name:      user.Name,
+	}
+}
+
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request, _ database.User) {
+	users, err := s.db.GetAllUsers(r.Context())
+	if err != nil {
+		respondWithDBError(w, "error getting users", err)
+		return
+	}
+	response := make([]userResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, newUserResponse(user))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request, _ database.User) {
+	userID, ok := pathUUID(w, r, "userID")
+	if !ok {
+		return
+	}
+	user, err := s.db.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithDBError(w, "error getting user", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || params.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "name and password are required")
+		return
+	}
+	if len(params.Password) < auth.MinPasswordLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("password must be at least %d characters long", auth.MinPasswordLength))
+		return
+	}
+	passwordHash, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error hashing password")
+		return
+	}
+	user, err := s.db.CreateUser(r.Context(), database.CreateUserParams{
+		ID:           uuid.New(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		Name:         params.Name,
+		PasswordHash: sql.NullString{String: passwordHash, Valid: true},
+	})
+	if err != nil {
+		respondWithDBError(w, "error creating user", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, newUserResponse(user))
+}
+
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	userID, ok := pathSelf(w, r, user)
+	if !ok {
+		return
+	}
+	deleted, err := s.db.DeleteUser(r.Context(), userID)
+	if err != nil {
+		respondWithDBError(w, "error deleting user", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type tokenResponse struct {
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	User      userResponse `json:"user"`
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+	user, err := s.db.GetUserByName(r.Context(), strings.TrimSpace(params.Name))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithDBError(w, "error getting user", err)
+		return
+	}
+	if err != nil || !user.PasswordHash.Valid || auth.CheckPasswordHash(params.Password, user.PasswordHash.String) != nil {
+		respondWithError(w, http.StatusUnauthorized, "incorrect name or password")
+		return
+	}
+	token, err := auth.MakeToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error generating token")
+		return
+	}
+	apiToken, err := s.db.CreateApiToken(r.Context(), database.CreateApiTokenParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(tokenLifetime),
+	})
+	if err != nil {
+		respondWithDBError(w, "error storing token", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, tokenResponse{
+		Token:     token,
+		ExpiresAt: apiToken.ExpiresAt,
+		User:      newUserResponse(user),
+	})
+}
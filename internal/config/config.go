@@ -7,9 +7,13 @@ import (
 
 const configFileName = "/.gatorconfig.json"
 
+// Config is the ~/.gatorconfig.json file. DbUrl is either a postgres:// URL
+// or a SQLite database, given as sqlite://path or a plain file path.
 type Config struct {
 	DbUrl           string `json:"db_url"`
 	CurrentUserName string `json:"current_user_name"`
+	SessionToken    string `json:"session_token,omitempty"`
+	MaxFeedFailures int    `json:"max_feed_failures,omitempty"`
 }
 
 func Read() (Config, error) {
@@ -28,17 +32,24 @@ func Read() (Config, error) {
 	return config, nil
 }
 
-func (c *Config) SetUser(user string) error {
+// SetSession stores the logged in user together with the session token that
+// proves it. An empty user and token log the current user out.
+func (c *Config) SetSession(user, token string) error {
+	c.CurrentUserName = user
+	c.SessionToken = token
+	return c.write()
+}
+
+func (c *Config) write() error {
 	userHomeDir, err := os.UserHomeDir()
 	if err != nil {
 		return err
 	}
-	c.CurrentUserName = user
 	jsonData, err := json.Marshal(c)
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(userHomeDir+configFileName, jsonData, 0644)
+	err = os.WriteFile(userHomeDir+configFileName, jsonData, 0600)
 	if err != nil {
 		return err
 	}
@@ -23,7 +23,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, consecutive_errors, last_error, last_status_code, last_succeeded_at, disabled_at, refresh_hints
 `
 
 type CreateFeedParams struct {
@@ -53,12 +53,139 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ConsecutiveErrors,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.LastSucceededAt,
+		&i.DisabledAt,
+		&i.RefreshHints,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :execrows
+DELETE FROM feeds
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteFeedParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteFeed(ctx context.Context, arg DeleteFeedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeed, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableFeed = `-- name: DisableFeed :exec
+UPDATE feeds
+SET disabled_at = $1, updated_at = $1
+WHERE id = $2
+`
+
+type DisableFeedParams struct {
+	DisabledAt sql.NullTime
+	ID         uuid.UUID
+}
+
+func (q *Queries) DisableFeed(ctx context.Context, arg DisableFeedParams) error {
+	_, err := q.db.ExecContext(ctx, disableFeed, arg.DisabledAt, arg.ID)
+	return err
+}
+
+const enableFeed = `-- name: EnableFeed :one
+UPDATE feeds
+SET disabled_at = NULL, consecutive_errors = 0, next_fetch_at = NULL, updated_at = $1
+WHERE url = $2
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, consecutive_errors, last_error, last_status_code, last_succeeded_at, disabled_at, refresh_hints
+`
+
+type EnableFeedParams struct {
+	UpdatedAt time.Time
+	Url       string
+}
+
+func (q *Queries) EnableFeed(ctx context.Context, arg EnableFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, enableFeed, arg.UpdatedAt, arg.Url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ConsecutiveErrors,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.LastSucceededAt,
+		&i.DisabledAt,
+		&i.RefreshHints,
+	)
+	return i, err
+}
+
+const getAllFeeds = `-- name: GetAllFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, consecutive_errors, last_error, last_status_code, last_succeeded_at, disabled_at, refresh_hints FROM feeds
+ORDER BY name ASC
+`
+
+func (q *Queries) GetAllFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getAllFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.FetchIntervalSeconds,
+			&i.NextFetchAt,
+			&i.ConsecutiveErrors,
+			&i.LastError,
+			&i.LastStatusCode,
+			&i.LastSucceededAt,
+			&i.DisabledAt,
+			&i.RefreshHints,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeedByUrl = `-- name: GetFeedByUrl :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, consecutive_errors, last_error, last_status_code, last_succeeded_at, disabled_at, refresh_hints FROM feeds
 WHERE url = $1
 `
 
@@ -73,6 +200,16 @@ func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ConsecutiveErrors,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.LastSucceededAt,
+		&i.DisabledAt,
+		&i.RefreshHints,
 	)
 	return i, err
 }
@@ -110,14 +247,76 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	return items, nil
 }
 
+const getFeedsWithErrors = `-- name: GetFeedsWithErrors :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, consecutive_errors, last_error, last_status_code, last_succeeded_at, disabled_at, refresh_hints FROM feeds
+WHERE consecutive_errors > 0 OR disabled_at IS NOT NULL
+ORDER BY consecutive_errors DESC, name ASC
+`
+
+func (q *Queries) GetFeedsWithErrors(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsWithErrors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.FetchIntervalSeconds,
+			&i.NextFetchAt,
+			&i.ConsecutiveErrors,
+			&i.LastError,
+			&i.LastStatusCode,
+			&i.LastSucceededAt,
+			&i.DisabledAt,
+			&i.RefreshHints,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1
+UPDATE feeds
+SET last_fetched_at = $1, updated_at = $1, next_fetch_at = $2
+WHERE id = (
+    SELECT id FROM feeds
+    WHERE disabled_at IS NULL
+    AND (next_fetch_at IS NULL OR next_fetch_at <= $1)
+    AND (last_fetched_at IS NULL OR last_fetched_at < $3)
+    ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, consecutive_errors, last_error, last_status_code, last_succeeded_at, disabled_at, refresh_hints
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+type GetNextFeedToFetchParams struct {
+	LastFetchedAt  sql.NullTime
+	NextFetchAt    sql.NullTime
+	RoundStartedAt sql.NullTime
+}
+
+func (q *Queries) GetNextFeedToFetch(ctx context.Context, arg GetNextFeedToFetchParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getNextFeedToFetch, arg.LastFetchedAt, arg.NextFetchAt, arg.RoundStartedAt)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -127,6 +326,16 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ConsecutiveErrors,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.LastSucceededAt,
+		&i.DisabledAt,
+		&i.RefreshHints,
 	)
 	return i, err
 }
@@ -146,3 +355,145 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams
 	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.LastFetchedAt, arg.ID)
 	return err
 }
+
+const recordFeedFailure = `-- name: RecordFeedFailure :one
+UPDATE feeds
+SET consecutive_errors = consecutive_errors + 1, last_error = $1, last_status_code = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, consecutive_errors, last_error, last_status_code, last_succeeded_at, disabled_at, refresh_hints
+`
+
+type RecordFeedFailureParams struct {
+	LastError      sql.NullString
+	LastStatusCode sql.NullInt32
+	ID             uuid.UUID
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure, arg.LastError, arg.LastStatusCode, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ConsecutiveErrors,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.LastSucceededAt,
+		&i.DisabledAt,
+		&i.RefreshHints,
+	)
+	return i, err
+}
+
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET consecutive_errors = 0, last_error = NULL, last_status_code = $1, last_succeeded_at = $2
+WHERE id = $3
+`
+
+type RecordFeedSuccessParams struct {
+	LastStatusCode  sql.NullInt32
+	LastSucceededAt sql.NullTime
+	ID              uuid.UUID
+}
+
+func (q *Queries) RecordFeedSuccess(ctx context.Context, arg RecordFeedSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess, arg.LastStatusCode, arg.LastSucceededAt, arg.ID)
+	return err
+}
+
+const scheduleNextFetch = `-- name: ScheduleNextFetch :exec
+UPDATE feeds
+SET next_fetch_at = $1
+WHERE id = $2
+`
+
+type ScheduleNextFetchParams struct {
+	NextFetchAt sql.NullTime
+	ID          uuid.UUID
+}
+
+func (q *Queries) ScheduleNextFetch(ctx context.Context, arg ScheduleNextFetchParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleNextFetch, arg.NextFetchAt, arg.ID)
+	return err
+}
+
+const setFeedFetchInterval = `-- name: SetFeedFetchInterval :one
+UPDATE feeds
+SET fetch_interval_seconds = $1, next_fetch_at = NULL, updated_at = $2
+WHERE url = $3
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, next_fetch_at, consecutive_errors, last_error, last_status_code, last_succeeded_at, disabled_at, refresh_hints
+`
+
+type SetFeedFetchIntervalParams struct {
+	FetchIntervalSeconds sql.NullInt32
+	UpdatedAt            time.Time
+	Url                  string
+}
+
+func (q *Queries) SetFeedFetchInterval(ctx context.Context, arg SetFeedFetchIntervalParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFetchInterval, arg.FetchIntervalSeconds, arg.UpdatedAt, arg.Url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.ConsecutiveErrors,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.LastSucceededAt,
+		&i.DisabledAt,
+		&i.RefreshHints,
+	)
+	return i, err
+}
+
+const updateFeedCache = `-- name: UpdateFeedCache :exec
+UPDATE feeds
+SET etag = $1, last_modified = $2
+WHERE id = $3
+`
+
+type UpdateFeedCacheParams struct {
+	Etag         sql.NullString
+	LastModified sql.NullString
+	ID           uuid.UUID
+}
+
+func (q *Queries) UpdateFeedCache(ctx context.Context, arg UpdateFeedCacheParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedCache, arg.Etag, arg.LastModified, arg.ID)
+	return err
+}
+
+const updateFeedRefreshHints = `-- name: UpdateFeedRefreshHints :exec
+UPDATE feeds
+SET refresh_hints = $1
+WHERE id = $2
+`
+
+type UpdateFeedRefreshHintsParams struct {
+	RefreshHints sql.NullString
+	ID           uuid.UUID
+}
+
+func (q *Queries) UpdateFeedRefreshHints(ctx context.Context, arg UpdateFeedRefreshHintsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedRefreshHints, arg.RefreshHints, arg.ID)
+	return err
+}
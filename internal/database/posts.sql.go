@@ -7,27 +7,104 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES (
-    $1,
-    $2,
-    $3,
-    $4,
-    $5,
-    $6,
-    $7,
-    $8
+const getPostGuidsByUrl = `-- name: GetPostGuidsByUrl :many
+SELECT guid FROM posts
+WHERE feed_id = $1 AND url = $2
+`
+
+type GetPostGuidsByUrlParams struct {
+	FeedID uuid.UUID
+	Url    string
+}
+
+func (q *Queries) GetPostGuidsByUrl(ctx context.Context, arg GetPostGuidsByUrlParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getPostGuidsByUrl, arg.FeedID, arg.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var guid string
+		if err := rows.Scan(&guid); err != nil {
+			return nil, err
+		}
+		items = append(items, guid)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsForUser = `-- name: GetPostsForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.guid,
+    feeds.name AS feed_name,
+    EXISTS (
+        SELECT 1 FROM post_revisions
+        WHERE post_revisions.post_id = posts.id
+    ) AS updated,
+    EXISTS (
+        SELECT 1 FROM post_reads
+        WHERE post_reads.post_id = posts.id AND post_reads.user_id = $1
+    ) AS is_read,
+    EXISTS (
+        SELECT 1 FROM post_stars
+        WHERE post_stars.post_id = posts.id AND post_stars.user_id = $1
+    ) AS starred
+FROM posts
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE posts.feed_id IN (
+    SELECT feed_id 
+    FROM feed_follows 
+    WHERE user_id = $1
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+AND (NOT $2::boolean OR NOT EXISTS (
+    SELECT 1 FROM post_reads
+    WHERE post_reads.post_id = posts.id AND post_reads.user_id = $1
+))
+AND ($3::text IS NULL OR feeds.name = $3 OR feeds.url = $3)
+AND ($4::timestamp IS NULL OR posts.published_at >= $4)
+AND ($5::timestamp IS NULL OR posts.published_at < $5)
+AND ($6::text IS NULL
+    OR posts.title ILIKE '%' || $6 || '%' ESCAPE '\'
+    OR posts.description ILIKE '%' || $6 || '%' ESCAPE '\')
+AND ($7::timestamp IS NULL
+    OR ($8::text = 'oldest' AND (posts.published_at, posts.id) > ($7, $9::uuid))
+    OR ($8::text <> 'oldest' AND (posts.published_at, posts.id) < ($7, $9::uuid)))
+ORDER BY
+    CASE WHEN $8::text = 'feed' THEN feeds.name END ASC,
+    CASE WHEN $8::text = 'oldest' THEN posts.published_at END ASC,
+    CASE WHEN $8::text = 'oldest' THEN posts.id END ASC,
+    posts.published_at DESC,
+    posts.id DESC
+LIMIT $10
 `
 
-type CreatePostParams struct {
+type GetPostsForUserParams struct {
+	UserID           uuid.UUID
+	UnreadOnly       bool
+	Feed             sql.NullString
+	Since            sql.NullTime
+	Before           sql.NullTime
+	Keyword          sql.NullString
+	AfterPublishedAt sql.NullTime
+	SortOrder        string
+	AfterID          uuid.NullUUID
+	Limit            int32
+}
+
+type GetPostsForUserRow struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
@@ -36,67 +113,116 @@ type CreatePostParams struct {
 	Description string
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Guid        string
+	FeedName    string
+	Updated     bool
+	IsRead      bool
+	Starred     bool
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.ID,
-		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
-		arg.FeedID,
-	)
-	var i Post
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Title,
-		&i.Url,
-		&i.Description,
-		&i.PublishedAt,
-		&i.FeedID,
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.Feed,
+		arg.Since,
+		arg.Before,
+		arg.Keyword,
+		arg.AfterPublishedAt,
+		arg.SortOrder,
+		arg.AfterID,
+		arg.Limit,
 	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsForUserRow
+	for rows.Next() {
+		var i GetPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Guid,
+			&i.FeedName,
+			&i.Updated,
+			&i.IsRead,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id FROM posts 
-WHERE feed_id IN (
-    SELECT feed_id 
-    FROM feed_follows 
-    WHERE user_id = $1
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, query) AS rank,
+    ts_headline('english', posts.description, query, 'StartSel=**, StopSel=**, MaxFragments=2, MaxWords=30') AS snippet
+FROM posts
+INNER JOIN feeds ON posts.feed_id = feeds.id
+CROSS JOIN websearch_to_tsquery('english', $1::text) AS query
+WHERE posts.feed_id IN (
+    SELECT feed_id
+    FROM feed_follows
+    WHERE user_id = $2
 )
-ORDER BY published_at DESC
-LIMIT $2
+AND posts.search_vector @@ query
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT $3
 `
 
-type GetPostsForUserParams struct {
+type SearchPostsForUserParams struct {
+	Query  string
 	UserID uuid.UUID
 	Limit  int32
 }
 
-func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+type SearchPostsForUserRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	PublishedAt time.Time
+	FeedName    string
+	Rank        float32
+	Snippet     string
+}
+
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser, arg.Query, arg.UserID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []SearchPostsForUserRow
 	for rows.Next() {
-		var i Post
+		var i SearchPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
 			&i.Title,
 			&i.Url,
-			&i.Description,
 			&i.PublishedAt,
-			&i.FeedID,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -110,3 +236,91 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const upsertPost = `-- name: UpsertPost :one
+WITH previous AS (
+    SELECT id, title, description FROM posts
+    WHERE feed_id = $8 AND guid = $9
+), upserted AS (
+    INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, guid)
+    VALUES (
+        $1,
+        $2,
+        $3,
+        $4,
+        $5,
+        $6,
+        $7,
+        $8,
+        $9
+    )
+    ON CONFLICT (feed_id, guid) DO UPDATE
+    SET title = EXCLUDED.title,
+        url = EXCLUDED.url,
+        description = EXCLUDED.description,
+        updated_at = EXCLUDED.updated_at
+    WHERE posts.title <> EXCLUDED.title OR posts.description <> EXCLUDED.description
+    RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, guid
+)
+SELECT
+    upserted.id, upserted.created_at, upserted.updated_at, upserted.title, upserted.url, upserted.description, upserted.published_at, upserted.feed_id, upserted.guid,
+    previous.title AS previous_title,
+    previous.description AS previous_description
+FROM upserted
+LEFT JOIN previous ON upserted.id = previous.id
+`
+
+type UpsertPostParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	Guid        string
+}
+
+type UpsertPostRow struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         string
+	PublishedAt         time.Time
+	FeedID              uuid.UUID
+	Guid                string
+	PreviousTitle       sql.NullString
+	PreviousDescription sql.NullString
+}
+
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (UpsertPostRow, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Guid,
+	)
+	var i UpsertPostRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Guid,
+		&i.PreviousTitle,
+		&i.PreviousDescription,
+	)
+	return i, err
+}
@@ -0,0 +1,372 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/panaiotuzunov/gator/internal/database"
+)
+
+// Store implements database.Store on top of the SQLite queries. The
+// generated types match the PostgreSQL ones field for field, so most methods
+// only convert between them.
+//
+// SQLite compares timestamps as text, so every time passed in is converted to
+// UTC first to keep comparisons and ordering correct.
+type Store struct {
+	q *Queries
+}
+
+var _ database.Store = (*Store)(nil)
+
+func NewStore(db DBTX) *Store {
+	return &Store{q: New(db)}
+}
+
+func (s *Store) Tx(tx *sql.Tx) database.Store {
+	return &Store{q: s.q.WithTx(tx)}
+}
+
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+func utcNull(t sql.NullTime) sql.NullTime {
+	if t.Valid {
+		t.Time = t.Time.UTC()
+	}
+	return t
+}
+
+func (s *Store) CreateApiToken(ctx context.Context, arg database.CreateApiTokenParams) (database.ApiToken, error) {
+	arg.CreatedAt, arg.ExpiresAt = utc(arg.CreatedAt), utc(arg.ExpiresAt)
+	token, err := s.q.CreateApiToken(ctx, CreateApiTokenParams(arg))
+	return database.ApiToken(token), err
+}
+
+func (s *Store) DeleteApiToken(ctx context.Context, tokenHash string) error {
+	return s.q.DeleteApiToken(ctx, tokenHash)
+}
+
+func (s *Store) DeleteApiTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	return s.q.DeleteApiTokensForUser(ctx, userID)
+}
+
+func (s *Store) GetUserByApiToken(ctx context.Context, arg database.GetUserByApiTokenParams) (database.User, error) {
+	arg.ExpiresAt = utc(arg.ExpiresAt)
+	user, err := s.q.GetUserByApiToken(ctx, GetUserByApiTokenParams(arg))
+	return database.User(user), err
+}
+
+func (s *Store) TouchApiToken(ctx context.Context, arg database.TouchApiTokenParams) error {
+	arg.LastUsedAt = utcNull(arg.LastUsedAt)
+	return s.q.TouchApiToken(ctx, TouchApiTokenParams(arg))
+}
+
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	arg.CreatedAt, arg.UpdatedAt = utc(arg.CreatedAt), utc(arg.UpdatedAt)
+	row, err := s.q.CreateFeedFollow(ctx, CreateFeedFollowParams(arg))
+	return database.CreateFeedFollowRow(row), err
+}
+
+func (s *Store) DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) error {
+	return s.q.DeleteFeedFollow(ctx, DeleteFeedFollowParams(arg))
+}
+
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	rows, err := s.q.GetFeedFollowsForUser(ctx, userID)
+	return convertAll(rows, err, func(row GetFeedFollowsForUserRow) database.GetFeedFollowsForUserRow {
+		return database.GetFeedFollowsForUserRow(row)
+	})
+}
+
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	arg.CreatedAt, arg.UpdatedAt = utc(arg.CreatedAt), utc(arg.UpdatedAt)
+	feed, err := s.q.CreateFeed(ctx, CreateFeedParams(arg))
+	return database.Feed(feed), err
+}
+
+func (s *Store) DeleteFeed(ctx context.Context, arg database.DeleteFeedParams) (int64, error) {
+	return s.q.DeleteFeed(ctx, DeleteFeedParams(arg))
+}
+
+func (s *Store) DisableFeed(ctx context.Context, arg database.DisableFeedParams) error {
+	arg.DisabledAt = utcNull(arg.DisabledAt)
+	return s.q.DisableFeed(ctx, DisableFeedParams(arg))
+}
+
+func (s *Store) EnableFeed(ctx context.Context, arg database.EnableFeedParams) (database.Feed, error) {
+	arg.UpdatedAt = utc(arg.UpdatedAt)
+	feed, err := s.q.EnableFeed(ctx, EnableFeedParams(arg))
+	return database.Feed(feed), err
+}
+
+func (s *Store) GetAllFeeds(ctx context.Context) ([]database.Feed, error) {
+	feeds, err := s.q.GetAllFeeds(ctx)
+	return convertAll(feeds, err, convertFeed)
+}
+
+func (s *Store) GetFeedByUrl(ctx context.Context, url string) (database.Feed, error) {
+	feed, err := s.q.GetFeedByUrl(ctx, url)
+	return database.Feed(feed), err
+}
+
+func (s *Store) GetFeeds(ctx context.Context) ([]database.GetFeedsRow, error) {
+	rows, err := s.q.GetFeeds(ctx)
+	return convertAll(rows, err, func(row GetFeedsRow) database.GetFeedsRow {
+		return database.GetFeedsRow(row)
+	})
+}
+
+func (s *Store) GetFeedsWithErrors(ctx context.Context) ([]database.Feed, error) {
+	feeds, err := s.q.GetFeedsWithErrors(ctx)
+	return convertAll(feeds, err, convertFeed)
+}
+
+func (s *Store) GetNextFeedToFetch(ctx context.Context, arg database.GetNextFeedToFetchParams) (database.Feed, error) {
+	arg.LastFetchedAt, arg.NextFetchAt, arg.RoundStartedAt = utcNull(arg.LastFetchedAt), utcNull(arg.NextFetchAt), utcNull(arg.RoundStartedAt)
+	feed, err := s.q.GetNextFeedToFetch(ctx, GetNextFeedToFetchParams(arg))
+	return database.Feed(feed), err
+}
+
+func (s *Store) RecordFeedFailure(ctx context.Context, arg database.RecordFeedFailureParams) (database.Feed, error) {
+	feed, err := s.q.RecordFeedFailure(ctx, RecordFeedFailureParams(arg))
+	return database.Feed(feed), err
+}
+
+func (s *Store) RecordFeedSuccess(ctx context.Context, arg database.RecordFeedSuccessParams) error {
+	arg.LastSucceededAt = utcNull(arg.LastSucceededAt)
+	return s.q.RecordFeedSuccess(ctx, RecordFeedSuccessParams(arg))
+}
+
+func (s *Store) ScheduleNextFetch(ctx context.Context, arg database.ScheduleNextFetchParams) error {
+	arg.NextFetchAt = utcNull(arg.NextFetchAt)
+	return s.q.ScheduleNextFetch(ctx, ScheduleNextFetchParams(arg))
+}
+
+func (s *Store) SetFeedFetchInterval(ctx context.Context, arg database.SetFeedFetchIntervalParams) (database.Feed, error) {
+	arg.UpdatedAt = utc(arg.UpdatedAt)
+	feed, err := s.q.SetFeedFetchInterval(ctx, SetFeedFetchIntervalParams(arg))
+	return database.Feed(feed), err
+}
+
+func (s *Store) UpdateFeedCache(ctx context.Context, arg database.UpdateFeedCacheParams) error {
+	return s.q.UpdateFeedCache(ctx, UpdateFeedCacheParams(arg))
+}
+
+func (s *Store) UpdateFeedRefreshHints(ctx context.Context, arg database.UpdateFeedRefreshHintsParams) error {
+	return s.q.UpdateFeedRefreshHints(ctx, UpdateFeedRefreshHintsParams(arg))
+}
+
+func (s *Store) MarkAllPostsRead(ctx context.Context, arg database.MarkAllPostsReadParams) (int64, error) {
+	arg.ReadAt = utc(arg.ReadAt)
+	return s.q.MarkAllPostsRead(ctx, MarkAllPostsReadParams(arg))
+}
+
+func (s *Store) MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error {
+	arg.ReadAt = utc(arg.ReadAt)
+	return s.q.MarkPostRead(ctx, MarkPostReadParams(arg))
+}
+
+func (s *Store) MarkPostUnread(ctx context.Context, arg database.MarkPostUnreadParams) error {
+	return s.q.MarkPostUnread(ctx, MarkPostUnreadParams(arg))
+}
+
+func (s *Store) CreatePostRevision(ctx context.Context, arg database.CreatePostRevisionParams) error {
+	arg.CreatedAt = utc(arg.CreatedAt)
+	return s.q.CreatePostRevision(ctx, CreatePostRevisionParams(arg))
+}
+
+func (s *Store) GetStarredPostsForUser(ctx context.Context, userID uuid.UUID) ([]database.PostStar, error) {
+	stars, err := s.q.GetStarredPostsForUser(ctx, userID)
+	return convertAll(stars, err, func(star PostStar) database.PostStar {
+		return database.PostStar(star)
+	})
+}
+
+func (s *Store) StarPost(ctx context.Context, arg database.StarPostParams) (int64, error) {
+	arg.CreatedAt = utc(arg.CreatedAt)
+	return s.q.StarPost(ctx, StarPostParams(arg))
+}
+
+func (s *Store) UnstarPost(ctx context.Context, arg database.UnstarPostParams) (int64, error) {
+	return s.q.UnstarPost(ctx, UnstarPostParams(arg))
+}
+
+func (s *Store) GetPostGuidsByUrl(ctx context.Context, arg database.GetPostGuidsByUrlParams) ([]string, error) {
+	return s.q.GetPostGuidsByUrl(ctx, GetPostGuidsByUrlParams(arg))
+}
+
+func (s *Store) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error) {
+	arg.Since, arg.Before = utcNull(arg.Since), utcNull(arg.Before)
+	arg.AfterPublishedAt = utcNull(arg.AfterPublishedAt)
+	rows, err := s.q.GetPostsForUser(ctx, GetPostsForUserParams(arg))
+	return convertAll(rows, err, func(row GetPostsForUserRow) database.GetPostsForUserRow {
+		return database.GetPostsForUserRow(row)
+	})
+}
+
+// SearchPostsForUser accepts the same web search syntax as the PostgreSQL
+// query: plain words, "quoted phrases", -excluded words and or.
+func (s *Store) SearchPostsForUser(ctx context.Context, arg database.SearchPostsForUserParams) ([]database.SearchPostsForUserRow, error) {
+	query := matchQuery(arg.Query)
+	if query == "" {
+		return nil, nil
+	}
+	rows, err := s.q.SearchPostsForUser(ctx, SearchPostsForUserParams{
+		Query:  query,
+		UserID: arg.UserID,
+		Limit:  arg.Limit,
+	})
+	return convertAll(rows, err, func(row SearchPostsForUserRow) database.SearchPostsForUserRow {
+		return database.SearchPostsForUserRow{
+			ID:          row.ID,
+			Title:       row.Title,
+			Url:         row.Url,
+			PublishedAt: row.PublishedAt,
+			FeedName:    row.FeedName,
+			Rank:        float32(row.Rank),
+			Snippet:     row.Snippet,
+		}
+	})
+}
+
+// UpsertPost reads the stored post before upserting it, since SQLite cannot
+// return the previous title and description from the upsert itself. Both run
+// in the caller's transaction if there is one.
+func (s *Store) UpsertPost(ctx context.Context, arg database.UpsertPostParams) (database.UpsertPostRow, error) {
+	previous, err := s.q.GetPostByGuid(ctx, GetPostByGuidParams{FeedID: arg.FeedID, Guid: arg.Guid})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return database.UpsertPostRow{}, err
+	}
+	found := err == nil
+	arg.CreatedAt, arg.UpdatedAt, arg.PublishedAt = utc(arg.CreatedAt), utc(arg.UpdatedAt), utc(arg.PublishedAt)
+	post, err := s.q.UpsertPost(ctx, UpsertPostParams(arg))
+	if err != nil {
+		return database.UpsertPostRow{}, err
+	}
+	return database.UpsertPostRow{
+		ID:                  post.ID,
+		CreatedAt:           post.CreatedAt,
+		UpdatedAt:           post.UpdatedAt,
+		Title:               post.Title,
+		Url:                 post.Url,
+		Description:         post.Description,
+		PublishedAt:         post.PublishedAt,
+		FeedID:              post.FeedID,
+		Guid:                post.Guid,
+		PreviousTitle:       sql.NullString{String: previous.Title, Valid: found},
+		PreviousDescription: sql.NullString{String: previous.Description, Valid: found},
+	}, nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	arg.CreatedAt, arg.UpdatedAt = utc(arg.CreatedAt), utc(arg.UpdatedAt)
+	user, err := s.q.CreateUser(ctx, CreateUserParams(arg))
+	return database.User(user), err
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	return s.q.DeleteUser(ctx, id)
+}
+
+func (s *Store) DeleteUsers(ctx context.Context) error {
+	return s.q.DeleteUsers(ctx)
+}
+
+func (s *Store) GetAllUsers(ctx context.Context) ([]database.User, error) {
+	users, err := s.q.GetAllUsers(ctx)
+	return convertAll(users, err, func(user User) database.User {
+		return database.User(user)
+	})
+}
+
+func (s *Store) GetUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.GetUser(ctx, id)
+	return database.User(user), err
+}
+
+func (s *Store) GetUserByName(ctx context.Context, name string) (database.User, error) {
+	user, err := s.q.GetUserByName(ctx, name)
+	return database.User(user), err
+}
+
+func (s *Store) GetUsers(ctx context.Context) ([]string, error) {
+	return s.q.GetUsers(ctx)
+}
+
+func (s *Store) SetUserPassword(ctx context.Context, arg database.SetUserPasswordParams) error {
+	arg.UpdatedAt = utc(arg.UpdatedAt)
+	return s.q.SetUserPassword(ctx, SetUserPasswordParams(arg))
+}
+
+func convertFeed(feed Feed) database.Feed {
+	return database.Feed(feed)
+}
+
+func convertAll[T, U any](items []T, err error, convert func(T) U) ([]U, error) {
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]U, 0, len(items))
+	for _, item := range items {
+		converted = append(converted, convert(item))
+	}
+	return converted, nil
+}
+
+// matchQuery turns a web search style query into an FTS5 MATCH expression.
+// Every term is quoted so punctuation in it is not read as FTS5 syntax.
+func matchQuery(query string) string {
+	var include, exclude []string
+	for _, term := range searchTerms(query) {
+		switch {
+		case strings.EqualFold(term, "or") && len(include) > 0:
+			include = append(include, "OR")
+		case strings.HasPrefix(term, "-") && len(term) > 1:
+			exclude = append(exclude, quoteTerm(term[1:]))
+		default:
+			include = append(include, quoteTerm(term))
+		}
+	}
+	if len(include) > 0 && include[len(include)-1] == "OR" {
+		include = include[:len(include)-1]
+	}
+	if len(include) == 0 {
+		return ""
+	}
+	match := strings.Join(include, " ")
+	for _, term := range exclude {
+		match += " NOT " + term
+	}
+	return match
+}
+
+// searchTerms splits query on whitespace, keeping "quoted phrases" together.
+func searchTerms(query string) []string {
+	var terms []string
+	for query = strings.TrimSpace(query); query != ""; query = strings.TrimSpace(query) {
+		if strings.HasPrefix(query, `"`) || strings.HasPrefix(query, `-"`) {
+			prefix := query[:strings.Index(query, `"`)]
+			rest := query[len(prefix)+1:]
+			phrase, after, _ := strings.Cut(rest, `"`)
+			if phrase != "" {
+				terms = append(terms, prefix+phrase)
+			}
+			query = after
+			continue
+		}
+		term, after, _ := strings.Cut(query, " ")
+		terms = append(terms, term)
+		query = after
+	}
+	return terms
+}
+
+func quoteTerm(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
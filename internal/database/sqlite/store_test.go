@@ -0,0 +1,312 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/panaiotuzunov/gator/internal/database"
+	"github.com/panaiotuzunov/gator/internal/storage"
+	"github.com/panaiotuzunov/gator/internal/storetest"
+)
+
+func newStore(t *testing.T) database.Store {
+	t.Helper()
+	return storetest.Open(t).Store
+}
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	alice := storetest.CreateUser(t, store, "alice")
+	storetest.CreateUser(t, store, "bob")
+
+	got, err := store.GetUserByName(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUserByName: %v", err)
+	}
+	if got.ID != alice.ID {
+		t.Errorf("GetUserByName returned %s, want %s", got.ID, alice.ID)
+	}
+	_, err = store.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), Name: "alice"})
+	if !storage.IsUniqueViolation(err) {
+		t.Errorf("creating a duplicate user returned %v, want a unique violation", err)
+	}
+	if _, err := store.GetUserByName(ctx, "carol"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByName for a missing user returned %v, want sql.ErrNoRows", err)
+	}
+	users, err := store.GetAllUsers(ctx)
+	if err != nil {
+		t.Fatalf("GetAllUsers: %v", err)
+	}
+	if len(users) != 2 || users[0].Name != "alice" || users[1].Name != "bob" {
+		t.Errorf("GetAllUsers returned %+v, want alice and bob", users)
+	}
+}
+
+func TestApiTokens(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	alice := storetest.CreateUser(t, store, "alice")
+	now := time.Now()
+	_, err := store.CreateApiToken(ctx, database.CreateApiTokenParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UserID:    alice.ID,
+		TokenHash: "hash",
+		ExpiresAt: now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateApiToken: %v", err)
+	}
+	user, err := store.GetUserByApiToken(ctx, database.GetUserByApiTokenParams{TokenHash: "hash", ExpiresAt: now})
+	if err != nil || user.ID != alice.ID {
+		t.Errorf("GetUserByApiToken returned %v, %v, want alice", user.Name, err)
+	}
+	_, err = store.GetUserByApiToken(ctx, database.GetUserByApiTokenParams{TokenHash: "hash", ExpiresAt: now.Add(2 * time.Hour)})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByApiToken after expiry returned %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestFeedFollows(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	alice := storetest.CreateUser(t, store, "alice")
+	feed := storetest.CreateFeed(t, store, alice, "Blog", "https://example.com/feed")
+
+	row := storetest.Follow(t, store, alice, feed)
+	if row.FeedName != "Blog" || row.UserName != "alice" {
+		t.Errorf("CreateFeedFollow returned feed %q and user %q, want Blog and alice", row.FeedName, row.UserName)
+	}
+	storetest.UpsertPost(t, store, feed, "1", "First", time.Now())
+	follows, err := store.GetFeedFollowsForUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetFeedFollowsForUser: %v", err)
+	}
+	if len(follows) != 1 || follows[0].FeedUrl != feed.Url || follows[0].UnreadCount != 1 {
+		t.Errorf("GetFeedFollowsForUser returned %+v, want one follow with one unread post", follows)
+	}
+	err = store.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{UserID: alice.ID, FeedID: feed.ID})
+	if err != nil {
+		t.Fatalf("DeleteFeedFollow: %v", err)
+	}
+	follows, err = store.GetFeedFollowsForUser(ctx, alice.ID)
+	if err != nil || len(follows) != 0 {
+		t.Errorf("GetFeedFollowsForUser after unfollowing returned %d follows, %v", len(follows), err)
+	}
+}
+
+func TestGetNextFeedToFetch(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	alice := storetest.CreateUser(t, store, "alice")
+	feed := storetest.CreateFeed(t, store, alice, "Blog", "https://example.com/feed")
+	now := time.Now()
+	lease := sql.NullTime{Time: now.Add(time.Minute), Valid: true}
+	round := sql.NullTime{Time: now, Valid: true}
+
+	claimed, err := store.GetNextFeedToFetch(ctx, database.GetNextFeedToFetchParams{
+		LastFetchedAt:  sql.NullTime{Time: now, Valid: true},
+		NextFetchAt:    lease,
+		RoundStartedAt: round,
+	})
+	if err != nil || claimed.ID != feed.ID {
+		t.Fatalf("GetNextFeedToFetch returned %v, %v, want the feed", claimed.Name, err)
+	}
+	_, err = store.GetNextFeedToFetch(ctx, database.GetNextFeedToFetchParams{
+		LastFetchedAt:  sql.NullTime{Time: now.Add(time.Second), Valid: true},
+		NextFetchAt:    lease,
+		RoundStartedAt: round,
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetNextFeedToFetch during the lease returned %v, want sql.ErrNoRows", err)
+	}
+	_, err = store.GetNextFeedToFetch(ctx, database.GetNextFeedToFetchParams{
+		LastFetchedAt:  sql.NullTime{Time: now.Add(2 * time.Minute), Valid: true},
+		NextFetchAt:    lease,
+		RoundStartedAt: round,
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetNextFeedToFetch after the lease but in the same round returned %v, want sql.ErrNoRows", err)
+	}
+	_, err = store.GetNextFeedToFetch(ctx, database.GetNextFeedToFetchParams{
+		LastFetchedAt:  sql.NullTime{Time: now.Add(2 * time.Minute), Valid: true},
+		NextFetchAt:    lease,
+		RoundStartedAt: sql.NullTime{Time: now.Add(2 * time.Minute), Valid: true},
+	})
+	if err != nil {
+		t.Errorf("GetNextFeedToFetch after the lease in a new round returned %v", err)
+	}
+}
+
+func TestUpsertPost(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	alice := storetest.CreateUser(t, store, "alice")
+	feed := storetest.CreateFeed(t, store, alice, "Blog", "https://example.com/feed")
+
+	first := storetest.UpsertPost(t, store, feed, "1", "Original", time.Now())
+	if first.PreviousTitle.Valid {
+		t.Errorf("new post has previous title %q", first.PreviousTitle.String)
+	}
+	_, err := store.UpsertPost(ctx, database.UpsertPostParams{
+		ID:          uuid.New(),
+		Title:       "Original",
+		Url:         "https://example.com/1",
+		Description: "About Original",
+		FeedID:      feed.ID,
+		Guid:        "1",
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("upserting an unchanged post returned %v, want sql.ErrNoRows", err)
+	}
+	edited := storetest.UpsertPost(t, store, feed, "1", "Edited", time.Now())
+	if edited.ID != first.ID {
+		t.Errorf("edited post has ID %s, want %s", edited.ID, first.ID)
+	}
+	if edited.PreviousTitle.String != "Original" {
+		t.Errorf("edited post has previous title %q, want Original", edited.PreviousTitle.String)
+	}
+}
+
+func TestGetPostsForUser(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	alice := storetest.CreateUser(t, store, "alice")
+	blog := storetest.CreateFeed(t, store, alice, "Blog", "https://example.com/feed")
+	news := storetest.CreateFeed(t, store, alice, "News", "https://news.example.com/feed")
+	storetest.Follow(t, store, alice, blog)
+	storetest.Follow(t, store, alice, news)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	oldest := storetest.UpsertPost(t, store, blog, "1", "Go release", base)
+	// A different time zone must not change the order.
+	middle := storetest.UpsertPost(t, store, news, "2", "Weather", base.Add(time.Hour).In(time.FixedZone("UTC+5", 5*3600)))
+	newest := storetest.UpsertPost(t, store, blog, "3", "Rust release", base.Add(2*time.Hour))
+	err := store.MarkPostRead(ctx, database.MarkPostReadParams{UserID: alice.ID, PostID: middle.ID, ReadAt: time.Now()})
+	if err != nil {
+		t.Fatalf("MarkPostRead: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		params database.GetPostsForUserParams
+		want   []uuid.UUID
+	}{
+		{
+			name:   "newest first",
+			params: database.GetPostsForUserParams{SortOrder: "newest"},
+			want:   []uuid.UUID{newest.ID, middle.ID, oldest.ID},
+		},
+		{
+			name:   "oldest first",
+			params: database.GetPostsForUserParams{SortOrder: "oldest"},
+			want:   []uuid.UUID{oldest.ID, middle.ID, newest.ID},
+		},
+		{
+			name:   "unread only",
+			params: database.GetPostsForUserParams{SortOrder: "newest", UnreadOnly: true},
+			want:   []uuid.UUID{newest.ID, oldest.ID},
+		},
+		{
+			name:   "by feed name",
+			params: database.GetPostsForUserParams{SortOrder: "newest", Feed: sql.NullString{String: "News", Valid: true}},
+			want:   []uuid.UUID{middle.ID},
+		},
+		{
+			name:   "keyword",
+			params: database.GetPostsForUserParams{SortOrder: "newest", Keyword: sql.NullString{String: "RELEASE", Valid: true}},
+			want:   []uuid.UUID{newest.ID, oldest.ID},
+		},
+		{
+			name:   "since",
+			params: database.GetPostsForUserParams{SortOrder: "newest", Since: sql.NullTime{Time: base.Add(30 * time.Minute), Valid: true}},
+			want:   []uuid.UUID{newest.ID, middle.ID},
+		},
+		{
+			name: "after cursor",
+			params: database.GetPostsForUserParams{
+				SortOrder:        "newest",
+				AfterPublishedAt: sql.NullTime{Time: newest.PublishedAt, Valid: true},
+				AfterID:          uuid.NullUUID{UUID: newest.ID, Valid: true},
+			},
+			want: []uuid.UUID{middle.ID, oldest.ID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.UserID = alice.ID
+			tt.params.Limit = 10
+			posts, err := store.GetPostsForUser(ctx, tt.params)
+			if err != nil {
+				t.Fatalf("GetPostsForUser: %v", err)
+			}
+			var got []uuid.UUID
+			for _, post := range posts {
+				got = append(got, post.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d posts, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("post %d is %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSearchPostsForUser(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	alice := storetest.CreateUser(t, store, "alice")
+	feed := storetest.CreateFeed(t, store, alice, "Blog", "https://example.com/feed")
+	storetest.Follow(t, store, alice, feed)
+	goPost := storetest.UpsertPost(t, store, feed, "1", "Go generics explained", time.Now())
+	storetest.UpsertPost(t, store, feed, "2", "Rust generics explained", time.Now())
+
+	search := func(query string) []database.SearchPostsForUserRow {
+		t.Helper()
+		results, err := store.SearchPostsForUser(ctx, database.SearchPostsForUserParams{Query: query, UserID: alice.ID, Limit: 10})
+		if err != nil {
+			t.Fatalf("searching %q: %v", query, err)
+		}
+		return results
+	}
+	if results := search("generics"); len(results) != 2 {
+		t.Errorf("searching generics returned %d results, want 2", len(results))
+	}
+	if results := search("generics -rust"); len(results) != 1 || results[0].ID != goPost.ID {
+		t.Errorf("searching generics -rust returned %+v, want the Go post", results)
+	}
+	if results := search(`"go generics" C++`); len(results) != 0 {
+		t.Errorf("searching with punctuation returned %d results, want 0", len(results))
+	}
+}
+
+func TestStars(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	alice := storetest.CreateUser(t, store, "alice")
+	feed := storetest.CreateFeed(t, store, alice, "Blog", "https://example.com/feed")
+	post := storetest.UpsertPost(t, store, feed, "1", "Keep me", time.Now())
+
+	starred, err := store.StarPost(ctx, database.StarPostParams{ID: uuid.New(), CreatedAt: time.Now(), UserID: alice.ID, PostID: post.ID})
+	if err != nil || starred != 1 {
+		t.Fatalf("StarPost returned %d, %v", starred, err)
+	}
+	if _, err := store.DeleteFeed(ctx, database.DeleteFeedParams{ID: feed.ID, UserID: alice.ID}); err != nil {
+		t.Fatalf("DeleteFeed: %v", err)
+	}
+	stars, err := store.GetStarredPostsForUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetStarredPostsForUser: %v", err)
+	}
+	if len(stars) != 1 || stars[0].Title != "Keep me" || stars[0].PostID.Valid {
+		t.Errorf("stars after deleting the feed are %+v, want the copied post with no post ID", stars)
+	}
+}
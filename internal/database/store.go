@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+// Store is the set of queries gator runs. *Queries implements it for
+// PostgreSQL and the sqlite package implements it for SQLite, so callers do
+// not depend on which engine is behind db_url.
+type Store interface {
+	// Tx returns a Store that runs its queries in tx.
+	Tx(tx *sql.Tx) Store
+
+	CreateApiToken(ctx context.Context, arg CreateApiTokenParams) (ApiToken, error)
+	DeleteApiToken(ctx context.Context, tokenHash string) error
+	DeleteApiTokensForUser(ctx context.Context, userID uuid.UUID) error
+	GetUserByApiToken(ctx context.Context, arg GetUserByApiTokenParams) (User, error)
+	TouchApiToken(ctx context.Context, arg TouchApiTokenParams) error
+
+	CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (CreateFeedFollowRow, error)
+	DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error
+	GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error)
+
+	CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error)
+	DeleteFeed(ctx context.Context, arg DeleteFeedParams) (int64, error)
+	DisableFeed(ctx context.Context, arg DisableFeedParams) error
+	EnableFeed(ctx context.Context, arg EnableFeedParams) (Feed, error)
+	GetAllFeeds(ctx context.Context) ([]Feed, error)
+	GetFeedByUrl(ctx context.Context, url string) (Feed, error)
+	GetFeeds(ctx context.Context) ([]GetFeedsRow, error)
+	GetFeedsWithErrors(ctx context.Context) ([]Feed, error)
+	GetNextFeedToFetch(ctx context.Context, arg GetNextFeedToFetchParams) (Feed, error)
+	RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (Feed, error)
+	RecordFeedSuccess(ctx context.Context, arg RecordFeedSuccessParams) error
+	ScheduleNextFetch(ctx context.Context, arg ScheduleNextFetchParams) error
+	SetFeedFetchInterval(ctx context.Context, arg SetFeedFetchIntervalParams) (Feed, error)
+	UpdateFeedCache(ctx context.Context, arg UpdateFeedCacheParams) error
+	UpdateFeedRefreshHints(ctx context.Context, arg UpdateFeedRefreshHintsParams) error
+
+	MarkAllPostsRead(ctx context.Context, arg MarkAllPostsReadParams) (int64, error)
+	MarkPostRead(ctx context.Context, arg MarkPostReadParams) error
+	MarkPostUnread(ctx context.Context, arg MarkPostUnreadParams) error
+
+	CreatePostRevision(ctx context.Context, arg CreatePostRevisionParams) error
+
+	GetStarredPostsForUser(ctx context.Context, userID uuid.UUID) ([]PostStar, error)
+	StarPost(ctx context.Context, arg StarPostParams) (int64, error)
+	UnstarPost(ctx context.Context, arg UnstarPostParams) (int64, error)
+
+	GetPostGuidsByUrl(ctx context.Context, arg GetPostGuidsByUrlParams) ([]string, error)
+	GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error)
+	SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error)
+	UpsertPost(ctx context.Context, arg UpsertPostParams) (UpsertPostRow, error)
+
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) (int64, error)
+	DeleteUsers(ctx context.Context) error
+	GetAllUsers(ctx context.Context) ([]User, error)
+	GetUser(ctx context.Context, id uuid.UUID) (User, error)
+	GetUserByName(ctx context.Context, name string) (User, error)
+	GetUsers(ctx context.Context) ([]string, error)
+	SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error
+}
+
+var _ Store = (*Queries)(nil)
+
+func (q *Queries) Tx(tx *sql.Tx) Store {
+	return q.WithTx(tx)
+}
@@ -0,0 +1,83 @@
+package fetch
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type atomFeed struct {
+	Title    atomText    `xml:"title"`
+	Subtitle atomText    `xml:"subtitle"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     atomText   `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Summary   atomText   `xml:"summary"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// atomText holds an Atom text construct. For type="xhtml" the payload is
+// markup nested inside the element, so the raw inner XML is kept instead.
+type atomText struct {
+	Type     string `xml:"type,attr"`
+	CharData string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.InnerXML)
+	}
+	return strings.TrimSpace(t.CharData)
+}
+
+func parseAtom(body []byte) (*RSSFeed, error) {
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, err
+	}
+	var data RSSFeed
+	data.Channel.Title = feed.Title.String()
+	data.Channel.Link = alternateLink(feed.Links)
+	data.Channel.Description = feed.Subtitle.String()
+	for _, entry := range feed.Entries {
+		description := firstNonEmpty(entry.Summary.String(), entry.Content.String())
+		pubDate := firstNonEmpty(entry.Published, entry.Updated)
+		data.Channel.Item = append(data.Channel.Item, RSSItem{
+			Title:       entry.Title.String(),
+			Link:        alternateLink(entry.Links),
+			Description: description,
+			PubDate:     pubDate,
+			GUID:        entry.ID,
+		})
+	}
+	return &data, nil
+}
+
+// alternateLink returns the href of the rel="alternate" link. A link without
+// a rel attribute is an alternate link per RFC 4287.
+func alternateLink(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
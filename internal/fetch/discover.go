@@ -0,0 +1,240 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxDocumentSize bounds how much of a page or candidate feed discovery reads.
+const maxDocumentSize = 10 << 20
+
+// feedTypes are the link types that announce a feed in an HTML page.
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+}
+
+// commonFeedPaths are tried on the site root when a page does not link its
+// feeds.
+var commonFeedPaths = []string{"/feed", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml", "/rss"}
+
+// ErrNoFeeds is returned by Discover when neither the page nor any of the
+// feeds it could point to is a feed.
+var ErrNoFeeds = errors.New("no feed found")
+
+// DiscoveredFeed is a feed found by Discover. It has already been fetched
+// and parsed successfully.
+type DiscoveredFeed struct {
+	URL   string
+	Title string
+}
+
+type document struct {
+	url         *url.URL
+	contentType string
+	body        []byte
+}
+
+// Discover returns the feeds available at pageURL. A URL that is already a
+// feed is returned as is. For an HTML page these are the feeds it announces
+// with <link rel="alternate"> tags or, failing that, the feeds found at
+// common paths on the same site. Candidates that cannot be fetched or do not
+// parse as a feed are left out.
+func Discover(ctx context.Context, pageURL string) ([]DiscoveredFeed, error) {
+	page, err := fetchDocument(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if feed, ok := parseDiscovered(page); ok {
+		feed.URL = pageURL
+		return []DiscoveredFeed{feed}, nil
+	}
+	feeds := fetchCandidates(ctx, feedLinks(page))
+	if len(feeds) == 0 {
+		var paths []link
+		for _, path := range commonFeedPaths {
+			paths = append(paths, link{url: page.url.ResolveReference(&url.URL{Path: path})})
+		}
+		feeds = fetchCandidates(ctx, paths)
+	}
+	if len(feeds) == 0 {
+		return nil, fmt.Errorf("%w at %s", ErrNoFeeds, pageURL)
+	}
+	return feeds, nil
+}
+
+type link struct {
+	url   *url.URL
+	title string
+}
+
+// fetchCandidates returns the candidates that are feeds, skipping duplicates.
+func fetchCandidates(ctx context.Context, candidates []link) []DiscoveredFeed {
+	var feeds []DiscoveredFeed
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		candidateURL := candidate.url.String()
+		if seen[candidateURL] {
+			continue
+		}
+		seen[candidateURL] = true
+		doc, err := fetchDocument(ctx, candidateURL)
+		if err != nil {
+			continue
+		}
+		feed, ok := parseDiscovered(doc)
+		if !ok {
+			continue
+		}
+		feed.URL = candidateURL
+		if feed.Title == "" {
+			feed.Title = candidate.title
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds
+}
+
+// parseDiscovered reports whether doc is a feed, and if so returns its
+// title. The URL is left for the caller, which knows the address the feed
+// was found under before any redirects.
+func parseDiscovered(doc *document) (DiscoveredFeed, bool) {
+	if !isFeed(doc.contentType, doc.body) {
+		return DiscoveredFeed{}, false
+	}
+	data, err := parseFeed(doc.contentType, doc.body)
+	if err != nil {
+		return DiscoveredFeed{}, false
+	}
+	return DiscoveredFeed{Title: html.UnescapeString(strings.TrimSpace(data.Channel.Title))}, true
+}
+
+// isFeed reports whether body is a JSON Feed or an XML document whose root
+// element is that of an RSS, Atom or RDF feed. parseFeed alone is not enough
+// since any XHTML page unmarshals into an empty RSSFeed.
+func isFeed(contentType string, body []byte) bool {
+	if isHTML(contentType) {
+		return false
+	}
+	if isJSONFeed(contentType, body) {
+		var feed jsonFeed
+		return json.Unmarshal(body, &feed) == nil && strings.HasPrefix(feed.Version, "https://jsonfeed.org/version/")
+	}
+	root, err := rootElement(body)
+	if err != nil {
+		return false
+	}
+	switch {
+	case root.Local == "rss":
+		return true
+	case root.Local == "feed" && root.Space == atomNamespace:
+		return true
+	case root.Local == "RDF" && root.Space == rdfNamespace:
+		return true
+	}
+	return false
+}
+
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// feedLinks returns the feeds a page announces in its <link> tags, resolved
+// against the page URL or its <base href>.
+func feedLinks(page *document) []link {
+	base := page.url
+	var links []link
+	tokenizer := html.NewTokenizer(bytes.NewReader(page.body))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		token := tokenizer.Token()
+		switch token.Data {
+		case "base":
+			href, err := url.Parse(strings.TrimSpace(attr(token, "href")))
+			if err == nil && href.String() != "" {
+				base = page.url.ResolveReference(href)
+			}
+		case "link":
+			if !hasToken(attr(token, "rel"), "alternate") {
+				continue
+			}
+			mediaType, _, err := mime.ParseMediaType(attr(token, "type"))
+			if err != nil || !feedTypes[mediaType] {
+				continue
+			}
+			href, err := url.Parse(strings.TrimSpace(attr(token, "href")))
+			if err != nil || href.String() == "" {
+				continue
+			}
+			links = append(links, link{url: href, title: strings.TrimSpace(attr(token, "title"))})
+		}
+	}
+	// A <base> tag applies to every link in the document, even ones before it.
+	for i := range links {
+		links[i].url = base.ResolveReference(links[i].url)
+	}
+	return links
+}
+
+func attr(token html.Token, name string) string {
+	for _, attribute := range token.Attr {
+		if attribute.Key == name {
+			return attribute.Val
+		}
+	}
+	return ""
+}
+
+// hasToken reports whether the space-separated list contains value, ignoring
+// case as HTML does for rel values.
+func hasToken(list, value string) bool {
+	for _, field := range strings.Fields(list) {
+		if strings.EqualFold(field, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func fetchDocument(ctx context.Context, documentURL string) (*document, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", documentURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request - %v", err)
+	}
+	req.Header.Set("User-Agent", "gator")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error getting a response - %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &StatusError{StatusCode: res.StatusCode, Status: res.Status}
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxDocumentSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body - %v", err)
+	}
+	return &document{
+		url:         res.Request.URL,
+		contentType: res.Header.Get("Content-Type"),
+		body:        body,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testRSS = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Posts &amp; notes</title></channel></rss>`
+	testAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</title></feed>`
+	testJSONFeed = `{"version": "https://jsonfeed.org/version/1.1", "title": "JSON", "items": []}`
+)
+
+func newSite(t *testing.T, pages map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasPrefix(page, "<!") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		io.WriteString(w, page)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDiscoverFeedURL(t *testing.T) {
+	server := newSite(t, map[string]string{"/feed.json": testJSONFeed})
+	feeds, err := Discover(context.Background(), server.URL+"/feed.json")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	want := []DiscoveredFeed{{URL: server.URL + "/feed.json", Title: "JSON"}}
+	if !reflect.DeepEqual(feeds, want) {
+		t.Errorf("Discover returned %+v, want %+v", feeds, want)
+	}
+}
+
+func TestDiscoverLinks(t *testing.T) {
+	server := newSite(t, map[string]string{
+		"/blog/post": `<!DOCTYPE html>
+<html><head>
+<link rel="stylesheet" type="text/css" href="style.css">
+<link rel="Alternate" type="application/rss+xml" title="RSS" href="rss.xml">
+<link rel="alternate" type="application/atom+xml" href="/atom.xml">
+<link rel="alternate" type="application/rss+xml" title="Missing" href="missing.xml">
+<link rel="alternate" type="application/rss+xml" title="Not a feed" href="/blog/post">
+<link rel="alternate" type="application/feed+json; charset=utf-8" title="From JSON" href="../feed.json">
+<link rel="alternate" type="application/rss+xml" href="rss.xml">
+<link rel="alternate" hreflang="de" type="text/html" href="/de/">
+<base href="/sub/">
+</head><body><a rel="alternate" type="application/rss+xml" href="ignored.xml">feed</a></body></html>`,
+		"/sub/rss.xml": testRSS,
+		"/atom.xml":    testAtom,
+		"/feed.json":   testJSONFeed,
+	})
+	feeds, err := Discover(context.Background(), server.URL+"/blog/post")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	want := []DiscoveredFeed{
+		{URL: server.URL + "/sub/rss.xml", Title: "Posts & notes"},
+		{URL: server.URL + "/atom.xml", Title: "Atom"},
+		{URL: server.URL + "/feed.json", Title: "JSON"},
+	}
+	if !reflect.DeepEqual(feeds, want) {
+		t.Errorf("Discover returned %+v, want %+v", feeds, want)
+	}
+}
+
+func TestDiscoverCommonPaths(t *testing.T) {
+	server := newSite(t, map[string]string{
+		"/blog/":    `<!DOCTYPE html><html><head><title>Blog</title></head></html>`,
+		"/feed":     `<!DOCTYPE html><html><head><title>Not a feed</title></head></html>`,
+		"/atom.xml": testAtom,
+	})
+	feeds, err := Discover(context.Background(), server.URL+"/blog/")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	want := []DiscoveredFeed{{URL: server.URL + "/atom.xml", Title: "Atom"}}
+	if !reflect.DeepEqual(feeds, want) {
+		t.Errorf("Discover returned %+v, want %+v", feeds, want)
+	}
+}
+
+func TestDiscoverNoFeeds(t *testing.T) {
+	server := newSite(t, map[string]string{
+		"/":      `<!DOCTYPE html><html><head><link rel="alternate" type="application/rss+xml" href="/gone.xml"></head></html>`,
+		"/xhtml": `<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"><head><title>Page</title></head></html>`,
+		"/api":   `{"status": "ok"}`,
+	})
+	for _, path := range []string{"/", "/xhtml", "/api"} {
+		if _, err := Discover(context.Background(), server.URL+path); !errors.Is(err, ErrNoFeeds) {
+			t.Errorf("Discover(%s) returned %v, want ErrNoFeeds", path, err)
+		}
+	}
+	var statusErr *StatusError
+	if _, err := Discover(context.Background(), server.URL+"/missing"); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Discover of a missing page returned %v, want a 404 StatusError", err)
+	}
+}
+
+func TestDiscoverTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(unblock) })
+	original := client
+	client = &http.Client{Timeout: 50 * time.Millisecond}
+	t.Cleanup(func() { client = original })
+
+	start := time.Now()
+	if _, err := Discover(context.Background(), server.URL); err == nil {
+		t.Error("Discover of a server that never answers succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Discover took %v to give up on a server that never answers", elapsed)
+	}
+}
@@ -1,12 +1,18 @@
 package fetch
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type RSSFeed struct {
@@ -15,6 +21,7 @@ type RSSFeed struct {
 		Link        string    `xml:"link"`
 		Description string    `xml:"description"`
 		Item        []RSSItem `xml:"item"`
+		RefreshHints
 	} `xml:"channel"`
 }
 
@@ -23,26 +30,79 @@ type RSSItem struct {
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	GUID        string `xml:"guid"`
 }
 
-func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+// Identifier returns the item's GUID, or a hash of its link and title when
+// the feed does not provide one.
+func (item RSSItem) Identifier() string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
+	}
+	sum := sha256.Sum256([]byte(item.Link + "\n" + item.Title))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// RequestTimeout bounds a single request, including reading the body, so a
+// server that stops responding cannot hang a fetch.
+const RequestTimeout = 30 * time.Second
+
+// client is shared by every request the package makes.
+var client = &http.Client{Timeout: RequestTimeout}
+
+// ErrNotModified is returned by FetchFeed when the server answers a
+// conditional request with 304 Not Modified.
+var ErrNotModified = errors.New("feed not modified")
+
+// StatusError is returned by FetchFeed when the server answers with a
+// status other than 2xx or 304.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status - %s", e.Status)
+}
+
+// CacheHeaders are the validators a server sent with the last successful
+// response for a feed. They are echoed back on the next request so an
+// unchanged feed can be answered with 304 Not Modified.
+type CacheHeaders struct {
+	ETag         string
+	LastModified string
+}
+
+func FetchFeed(ctx context.Context, feedURL string, cache CacheHeaders) (*RSSFeed, CacheHeaders, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
-		return &RSSFeed{}, fmt.Errorf("error creating request - %v", err)
+		return &RSSFeed{}, cache, fmt.Errorf("error creating request - %v", err)
 	}
 	req.Header.Set("User-Agent", "gator")
-	client := http.Client{}
+	if cache.ETag != "" {
+		req.Header.Set("If-None-Match", cache.ETag)
+	}
+	if cache.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cache.LastModified)
+	}
 	res, err := client.Do(req)
 	if err != nil {
-		return &RSSFeed{}, fmt.Errorf("error getting a response - %v", err)
+		return &RSSFeed{}, cache, fmt.Errorf("error getting a response - %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified {
+		return &RSSFeed{}, cache, ErrNotModified
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &RSSFeed{}, cache, &StatusError{StatusCode: res.StatusCode, Status: res.Status}
 	}
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return &RSSFeed{}, fmt.Errorf("error reading response body - %v", err)
+		return &RSSFeed{}, cache, fmt.Errorf("error reading response body - %v", err)
 	}
-	var data RSSFeed
-	if err := xml.Unmarshal(body, &data); err != nil {
-		return &RSSFeed{}, fmt.Errorf("error unmarshaling body - %v", err)
+	data, err := parseFeed(res.Header.Get("Content-Type"), body)
+	if err != nil {
+		return &RSSFeed{}, cache, fmt.Errorf("error unmarshaling body - %v", err)
 	}
 	data.Channel.Title = html.UnescapeString(data.Channel.Title)
 	data.Channel.Description = html.UnescapeString(data.Channel.Description)
@@ -50,5 +110,45 @@ func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 		data.Channel.Item[i].Title = html.UnescapeString(data.Channel.Item[i].Title)
 		data.Channel.Item[i].Description = html.UnescapeString(data.Channel.Item[i].Description)
 	}
+	newCache := CacheHeaders{
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+	}
+	return data, newCache, nil
+}
+
+// parseFeed detects the feed format from the Content-Type header and the
+// document itself and normalizes it into an RSSFeed.
+func parseFeed(contentType string, body []byte) (*RSSFeed, error) {
+	if isJSONFeed(contentType, body) {
+		return parseJSONFeed(body)
+	}
+	root, err := rootElement(body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case root.Local == "feed" && root.Space == atomNamespace:
+		return parseAtom(body)
+	case root.Local == "RDF" && root.Space == rdfNamespace:
+		return parseRDF(body)
+	}
+	var data RSSFeed
+	if err := xml.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
 	return &data, nil
 }
+
+func rootElement(body []byte) (xml.Name, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package fetch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		item RSSItem
+		want string
+	}{
+		{"guid", RSSItem{GUID: " tag:example.com,2024:1 ", Link: "https://example.com/1"}, "tag:example.com,2024:1"},
+		{"guid without a link", RSSItem{GUID: "urn:uuid:1", Title: "Hello"}, "urn:uuid:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.item.Identifier(); got != tt.want {
+				t.Errorf("Identifier() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	first := RSSItem{Title: "Hello", Link: "https://example.com/1"}.Identifier()
+	if !strings.HasPrefix(first, "sha256:") {
+		t.Errorf("Identifier() of an item without a GUID = %q, want a content hash", first)
+	}
+	if first != (RSSItem{Title: "Hello", Link: "https://example.com/1"}).Identifier() {
+		t.Error("Identifier() of the same item changed between calls")
+	}
+	if first == (RSSItem{Title: "Other", Link: "https://example.com/1"}).Identifier() {
+		t.Error("Identifier() of items sharing a link is the same")
+	}
+}
+
+func newFeed(title, link, description string, hints RefreshHints, items ...RSSItem) *RSSFeed {
+	var feed RSSFeed
+	feed.Channel.Title = title
+	feed.Channel.Link = link
+	feed.Channel.Description = description
+	feed.Channel.RefreshHints = hints
+	feed.Channel.Item = items
+	return &feed
+}
+
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        *RSSFeed
+	}{
+		{
+			name:        "rss",
+			contentType: "application/rss+xml",
+			body: `<?xml version="1.0"?>
+<rss version="2.0" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/"><channel>
+<title>Blog</title><link>https://example.com</link><description>A blog</description>
+<ttl>60</ttl><skipHours><hour>1</hour><hour>2</hour></skipHours><skipDays><day>Sunday</day></skipDays>
+<sy:updatePeriod>daily</sy:updatePeriod><sy:updateFrequency>2</sy:updateFrequency>
+<item><title>Hello</title><link>https://example.com/1</link><description>Text</description>
+<pubDate>Mon, 01 Jan 2024 10:00:00 +0000</pubDate><guid>1</guid></item>
+</channel></rss>`,
+			want: newFeed("Blog", "https://example.com", "A blog", RefreshHints{
+				TTL:             "60",
+				SkipHours:       []string{"1", "2"},
+				SkipDays:        []string{"Sunday"},
+				UpdatePeriod:    "daily",
+				UpdateFrequency: "2",
+			}, RSSItem{Title: "Hello", Link: "https://example.com/1", Description: "Text", PubDate: "Mon, 01 Jan 2024 10:00:00 +0000", GUID: "1"}),
+		},
+		{
+			name:        "atom",
+			contentType: "application/atom+xml",
+			body: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title> Atom </title><subtitle>Notes</subtitle>
+<link rel="self" href="https://example.com/atom.xml"/><link href="https://example.com/"/>
+<entry><id>urn:1</id><title>First</title>
+<link rel="edit" href="https://example.com/edit/1"/><link rel="alternate" href="https://example.com/1"/>
+<published>2024-01-01T10:00:00Z</published><updated>2024-01-02T10:00:00Z</updated>
+<summary>Summary</summary><content>Content</content></entry>
+<entry><id>urn:2</id><title type="html">&lt;b&gt;Second&lt;/b&gt;</title>
+<link rel="related" href="https://example.com/related"/>
+<updated>2024-01-03T10:00:00Z</updated>
+<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Markup</p></div></content></entry>
+</feed>`,
+			want: newFeed("Atom", "https://example.com/", "Notes", RefreshHints{},
+				RSSItem{Title: "First", Link: "https://example.com/1", Description: "Summary", PubDate: "2024-01-01T10:00:00Z", GUID: "urn:1"},
+				RSSItem{Title: "<b>Second</b>", Link: "https://example.com/related", Description: `<div xmlns="http://www.w3.org/1999/xhtml"><p>Markup</p></div>`, PubDate: "2024-01-03T10:00:00Z", GUID: "urn:2"},
+			),
+		},
+		{
+			name:        "json feed",
+			contentType: "application/feed+json",
+			body: `{"version": "https://jsonfeed.org/version/1.1", "title": "JSON", "home_page_url": "https://example.com/", "description": "A JSON feed",
+"items": [
+{"id": "1", "url": "https://example.com/1", "title": "First", "content_html": "<p>HTML</p>", "content_text": "Text", "date_published": "2024-01-01T10:00:00Z"},
+{"id": "2", "external_url": "https://other.example/2", "title": "Second", "summary": "Summary", "date_modified": "2024-01-02T10:00:00Z"}
+]}`,
+			want: newFeed("JSON", "https://example.com/", "A JSON feed", RefreshHints{},
+				RSSItem{Title: "First", Link: "https://example.com/1", Description: "<p>HTML</p>", PubDate: "2024-01-01T10:00:00Z", GUID: "1"},
+				RSSItem{Title: "Second", Link: "https://other.example/2", Description: "Summary", PubDate: "2024-01-02T10:00:00Z", GUID: "2"},
+			),
+		},
+		{
+			name:        "json feed sniffed from the body",
+			contentType: "text/plain",
+			body:        ` {"version": "https://jsonfeed.org/version/1", "title": "JSON", "items": [{"id": "1", "title": "First"}]}`,
+			want:        newFeed("JSON", "", "", RefreshHints{}, RSSItem{Title: "First", GUID: "1"}),
+		},
+		{
+			name:        "rdf",
+			contentType: "application/rdf+xml",
+			body: `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/"
+ xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/">
+<channel rdf:about="https://example.com/"><title>RDF</title><link>https://example.com/</link><description>Old school</description>
+<sy:updatePeriod>hourly</sy:updatePeriod></channel>
+<item rdf:about="https://example.com/1"><title>First</title><link>https://example.com/1</link>
+<description>Text</description><dc:date>2024-01-01T10:00:00Z</dc:date></item>
+</rdf:RDF>`,
+			want: newFeed("RDF", "https://example.com/", "Old school", RefreshHints{UpdatePeriod: "hourly"},
+				RSSItem{Title: "First", Link: "https://example.com/1", Description: "Text", PubDate: "2024-01-01T10:00:00Z", GUID: "https://example.com/1"},
+			),
+		},
+		{
+			name:        "atom sniffed despite an rss content type",
+			contentType: "application/rss+xml",
+			body:        `<feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</title><entry><id>1</id><title>First</title></entry></feed>`,
+			want:        newFeed("Atom", "", "", RefreshHints{}, RSSItem{Title: "First", GUID: "1"}),
+		},
+		{
+			name:        "feed root outside the atom namespace is read as rss",
+			contentType: "application/xml",
+			body:        `<feed><title>Not Atom</title></feed>`,
+			want:        newFeed("", "", "", RefreshHints{}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFeed(tt.contentType, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("parseFeed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFeed returned\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"empty", "application/rss+xml", ""},
+		{"truncated xml", "application/rss+xml", "<?xml version=\"1.0\"?>\n"},
+		{"broken atom", "application/atom+xml", `<feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</feed>`},
+		{"broken json", "application/feed+json", `{"title": `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if feed, err := parseFeed(tt.contentType, []byte(tt.body)); err == nil {
+				t.Errorf("parseFeed returned %+v, want an error", feed)
+			}
+		})
+	}
+}
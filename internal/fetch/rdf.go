@@ -0,0 +1,47 @@
+package fetch
+
+import "encoding/xml"
+
+const rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// rdfFeed is an RSS 1.0 document. Unlike RSS 2.0 the items are siblings of
+// the channel rather than its children.
+type rdfFeed struct {
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		RefreshHints
+	} `xml:"channel"`
+	Items []rdfItem `xml:"item"`
+}
+
+type rdfItem struct {
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+func parseRDF(body []byte) (*RSSFeed, error) {
+	var feed rdfFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, err
+	}
+	var data RSSFeed
+	data.Channel.Title = feed.Channel.Title
+	data.Channel.Link = feed.Channel.Link
+	data.Channel.Description = feed.Channel.Description
+	data.Channel.RefreshHints = feed.Channel.RefreshHints
+	for _, item := range feed.Items {
+		data.Channel.Item = append(data.Channel.Item, RSSItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			PubDate:     item.Date,
+			GUID:        item.About,
+		})
+	}
+	return &data, nil
+}
@@ -0,0 +1,88 @@
+package fetch
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScheduleSteps bounds the search for an hour that is not excluded by
+// skipHours/skipDays, so a feed that skips every hour cannot loop forever.
+const maxScheduleSteps = 7 * 24
+
+// RefreshHints are the publisher's polling hints from RSS <ttl>, <skipHours>,
+// <skipDays> and the syndication module's sy:updatePeriod/sy:updateFrequency.
+type RefreshHints struct {
+	TTL             string   `xml:"ttl"`
+	SkipHours       []string `xml:"skipHours>hour"`
+	SkipDays        []string `xml:"skipDays>day"`
+	UpdatePeriod    string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+}
+
+// IsZero reports whether the feed gave no refresh hints at all.
+func (h RefreshHints) IsZero() bool {
+	return h.TTL == "" && len(h.SkipHours) == 0 && len(h.SkipDays) == 0 && h.UpdatePeriod == "" && h.UpdateFrequency == ""
+}
+
+// MinInterval returns the shortest polling interval the publisher asks for,
+// or zero when the feed gives no hint.
+func (h RefreshHints) MinInterval() time.Duration {
+	var interval time.Duration
+	if minutes, err := strconv.Atoi(strings.TrimSpace(h.TTL)); err == nil && minutes > 0 {
+		interval = time.Duration(minutes) * time.Minute
+	}
+	if period := updatePeriod(h.UpdatePeriod); period > 0 {
+		frequency, err := strconv.Atoi(strings.TrimSpace(h.UpdateFrequency))
+		if err != nil || frequency < 1 {
+			frequency = 1
+		}
+		interval = max(interval, period/time.Duration(frequency))
+	}
+	return interval
+}
+
+// NextFetch returns when the feed should be polled again, given the time of
+// the current fetch and the configured interval. The interval is stretched to
+// the publisher's minimum and then moved past any skipped hours or days.
+func (h RefreshHints) NextFetch(from time.Time, interval time.Duration) time.Time {
+	next := from.Add(max(interval, h.MinInterval()))
+	for range maxScheduleSteps {
+		if !h.skips(next) {
+			return next
+		}
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return from.Add(interval)
+}
+
+func (h RefreshHints) skips(t time.Time) bool {
+	t = t.UTC()
+	for _, hour := range h.SkipHours {
+		if skipped, err := strconv.Atoi(strings.TrimSpace(hour)); err == nil && skipped == t.Hour() {
+			return true
+		}
+	}
+	for _, day := range h.SkipDays {
+		if strings.EqualFold(strings.TrimSpace(day), t.Weekday().String()) {
+			return true
+		}
+	}
+	return false
+}
+
+func updatePeriod(period string) time.Duration {
+	switch strings.ToLower(strings.TrimSpace(period)) {
+	case "hourly":
+		return time.Hour
+	case "daily":
+		return 24 * time.Hour
+	case "weekly":
+		return 7 * 24 * time.Hour
+	case "monthly":
+		return 30 * 24 * time.Hour
+	case "yearly":
+		return 365 * 24 * time.Hour
+	}
+	return 0
+}
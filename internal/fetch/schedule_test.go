@@ -0,0 +1,42 @@
+package fetch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextFetch(t *testing.T) {
+	// A Monday.
+	from := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		hints    RefreshHints
+		from     time.Time
+		interval time.Duration
+		want     time.Time
+	}{
+		{"no hints", RefreshHints{}, from, time.Hour, from.Add(time.Hour)},
+		{"ttl longer than the interval", RefreshHints{TTL: " 120 "}, from, time.Hour, from.Add(2 * time.Hour)},
+		{"ttl shorter than the interval", RefreshHints{TTL: "10"}, from, time.Hour, from.Add(time.Hour)},
+		{"invalid ttl", RefreshHints{TTL: "soon"}, from, time.Hour, from.Add(time.Hour)},
+		{"negative ttl", RefreshHints{TTL: "-60"}, from, time.Hour, from.Add(time.Hour)},
+		{"update period", RefreshHints{UpdatePeriod: "Daily"}, from, time.Hour, from.Add(24 * time.Hour)},
+		{"update period with frequency", RefreshHints{UpdatePeriod: "daily", UpdateFrequency: "4"}, from, time.Hour, from.Add(6 * time.Hour)},
+		{"update period with invalid frequency", RefreshHints{UpdatePeriod: "hourly", UpdateFrequency: "0"}, from, time.Minute, from.Add(time.Hour)},
+		{"unknown update period", RefreshHints{UpdatePeriod: "fortnightly"}, from, time.Hour, from.Add(time.Hour)},
+		{"longest of ttl and update period", RefreshHints{TTL: "300", UpdatePeriod: "hourly"}, from, time.Hour, from.Add(5 * time.Hour)},
+		{"skipped hour", RefreshHints{SkipHours: []string{"11"}}, from, time.Hour, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{"consecutive skipped hours", RefreshHints{SkipHours: []string{"11", " 12", "13"}}, from, time.Hour, time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)},
+		{"skipped hours are in UTC", RefreshHints{SkipHours: []string{"11"}}, from.In(time.FixedZone("UTC+5", 5*60*60)), time.Hour, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{"skipped day", RefreshHints{SkipDays: []string{"monday"}}, from, time.Hour, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"skipped day and hour", RefreshHints{SkipDays: []string{"Tuesday"}, SkipHours: []string{"0"}}, from, 14 * time.Hour, time.Date(2024, 1, 3, 1, 0, 0, 0, time.UTC)},
+		{"every hour skipped", RefreshHints{SkipDays: []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}}, from, time.Hour, from.Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hints.NextFetch(tt.from, tt.interval); !got.Equal(tt.want) {
+				t.Errorf("NextFetch() = %v, want %v", got.UTC(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// Package migrate applies the goose-format migrations embedded in the binary
+// and records the applied versions in the schema_migrations table.
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration together with when it was applied, if it was.
+type Status struct {
+	Migration
+	AppliedAt sql.NullTime
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads every NNN_name.sql file in the root of fsys, sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	seen := make(map[int64]string)
+	for _, name := range names {
+		versionStr, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: file name must start with a version followed by _", name)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, versionStr)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		up, down, err := split(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    strings.TrimSuffix(path.Base(name), ".sql"),
+			Up:      up,
+			Down:    down,
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// split separates the "-- +goose Up" and "-- +goose Down" sections of a
+// migration. StatementBegin/End markers are dropped since each section is
+// executed as a single multi-statement query.
+func split(content string) (string, string, error) {
+	var up, down strings.Builder
+	var current *strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch strings.TrimSpace(line) {
+		case "-- +goose Up":
+			current = &up
+			continue
+		case "-- +goose Down":
+			current = &down
+			continue
+		case "-- +goose StatementBegin", "-- +goose StatementEnd":
+			continue
+		}
+		if current == nil {
+			if strings.TrimSpace(line) != "" && !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				return "", "", fmt.Errorf("statement before the -- +goose Up marker")
+			}
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+	if strings.TrimSpace(up.String()) == "" {
+		return "", "", fmt.Errorf("missing -- +goose Up section")
+	}
+	return up.String(), down.String(), nil
+}
+
+// Latest returns the version of the newest embedded migration.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status reports every embedded migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = sql.NullTime{Time: appliedAt, Valid: true}
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the embedded migrations that have not been applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration in version order, each in its own
+// transaction, and returns the ones it applied. It creates the
+// schema_migrations table on first use.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	exists, err := m.tableExists(ctx, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := m.createTable(ctx); err != nil {
+			return nil, fmt.Errorf("preparing schema_migrations: %w", err)
+		}
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, migration := range pending {
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)", migration.Version, time.Now())
+			return err
+		})
+		if err != nil {
+			return pending[:i], fmt.Errorf("applying migration %s: %w", migration.Name, err)
+		}
+	}
+	return pending, nil
+}
+
+// Down rolls back the most recently applied migration. It returns nil if no
+// migration is applied.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	exists, err := m.tableExists(ctx, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists && len(applied) > 0 {
+		return nil, fmt.Errorf("schema_migrations does not exist yet - migrate up to adopt the versions recorded by goose")
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			if strings.TrimSpace(migration.Down) != "" {
+				if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("rolling back migration %s: %w", migration.Name, err)
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
+
+// applied returns the applied versions. Until Up creates the
+// schema_migrations table these are the versions recorded by the goose CLI,
+// for a database set up with it before migrations were embedded, and none
+// otherwise. The SQL is kept portable since the same migrator runs against
+// PostgreSQL and SQLite.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	exists, err := m.tableExists(ctx, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return m.gooseVersions(ctx)
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// tableExists reports whether table exists. PostgreSQL lists its tables in
+// information_schema and SQLite in sqlite_master. Each engine lacks the other
+// catalog, so both are tried and an error is only returned when neither can
+// be read.
+func (m *Migrator) tableExists(ctx context.Context, table string) (bool, error) {
+	var count int
+	err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1", table).Scan(&count)
+	if err == nil {
+		return count > 0, nil
+	}
+	sqliteErr := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = $1", table).Scan(&count)
+	if sqliteErr == nil {
+		return count > 0, nil
+	}
+	return false, fmt.Errorf("checking for table %s: %w", table, errors.Join(err, sqliteErr))
+}
+
+func (m *Migrator) createTable(ctx context.Context) error {
+	gooseVersions, err := m.gooseVersions(ctx)
+	if err != nil {
+		return err
+	}
+	return m.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `CREATE TABLE schema_migrations (
+    version BIGINT PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL
+)`)
+		if err != nil {
+			return err
+		}
+		for version, appliedAt := range gooseVersions {
+			_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)", version, appliedAt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// gooseVersions returns the versions goose considers applied: those whose
+// latest row in goose_db_version has is_applied set.
+func (m *Migrator) gooseVersions(ctx context.Context) (map[int64]time.Time, error) {
+	exists, err := m.tableExists(ctx, "goose_db_version")
+	if err != nil || !exists {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version_id, is_applied, tstamp FROM goose_db_version WHERE version_id > 0 ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &isApplied, &appliedAt); err != nil {
+			return nil, err
+		}
+		if isApplied {
+			versions[version] = appliedAt
+		} else {
+			delete(versions, version)
+		}
+	}
+	return versions, rows.Err()
+}
+
+func (m *Migrator) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
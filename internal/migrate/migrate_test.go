@@ -0,0 +1,123 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/panaiotuzunov/gator/internal/migrate"
+	"github.com/panaiotuzunov/gator/internal/storage"
+)
+
+var testMigrations = fstest.MapFS{
+	"001_users.sql": {Data: []byte("-- +goose Up\nCREATE TABLE users (id INTEGER PRIMARY KEY);\n\n-- +goose Down\nDROP TABLE users;\n")},
+	"002_feeds.sql": {Data: []byte("-- +goose Up\nCREATE TABLE feeds (id INTEGER PRIMARY KEY);\n\n-- +goose Down\nDROP TABLE feeds;\n")},
+}
+
+func newMigrator(t *testing.T) (*migrate.Migrator, func(table string) bool) {
+	t.Helper()
+	conn, err := storage.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	migrator, err := migrate.New(conn, testMigrations)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	exists := func(table string) bool {
+		t.Helper()
+		var count int
+		if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = ?", table).Scan(&count); err != nil {
+			t.Fatalf("looking up %s: %v", table, err)
+		}
+		return count > 0
+	}
+	return migrator, exists
+}
+
+func TestStatusDoesNotCreateTable(t *testing.T) {
+	ctx := context.Background()
+	migrator, exists := newMigrator(t)
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("Pending returned %d migrations, %v, want both", len(pending), err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt.Valid {
+			t.Errorf("%s is applied in a new database", status.Name)
+		}
+	}
+	if migration, err := migrator.Down(ctx); migration != nil || err != nil {
+		t.Errorf("Down in a new database returned %v, %v, want nothing to roll back", migration, err)
+	}
+	if exists("schema_migrations") {
+		t.Error("reading the migration status created schema_migrations")
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("Up applied %d migrations, %v, want both", len(applied), err)
+	}
+	if !exists("schema_migrations") || !exists("feeds") {
+		t.Error("Up did not create schema_migrations and the migrated tables")
+	}
+	if pending, err := migrator.Pending(ctx); err != nil || len(pending) != 0 {
+		t.Errorf("Pending after Up returned %d migrations, %v", len(pending), err)
+	}
+}
+
+func TestAdoptsGooseVersions(t *testing.T) {
+	ctx := context.Background()
+	conn, err := storage.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+	defer conn.Close()
+	_, err = conn.Exec(`CREATE TABLE goose_db_version (id INTEGER PRIMARY KEY, version_id BIGINT, is_applied BOOLEAN, tstamp TIMESTAMP);
+CREATE TABLE users (id INTEGER PRIMARY KEY);
+INSERT INTO goose_db_version (version_id, is_applied, tstamp) VALUES (0, 1, ?), (1, 1, ?);`, time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("setting up goose history: %v", err)
+	}
+	migrator, err := migrate.New(conn, testMigrations)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil || len(pending) != 1 || pending[0].Version != 2 {
+		t.Fatalf("Pending returned %+v, %v, want only version 2", pending, err)
+	}
+	if _, err := migrator.Down(ctx); err == nil {
+		t.Error("Down succeeded before the goose history was adopted")
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil || len(applied) != 1 || applied[0].Version != 2 {
+		t.Fatalf("Up applied %+v, %v, want only version 2", applied, err)
+	}
+	if migration, err := migrator.Down(ctx); err != nil || migration.Version != 2 {
+		t.Errorf("Down after adopting the goose history returned %v, %v, want version 2", migration, err)
+	}
+}
+
+func TestStatusReportsDatabaseErrors(t *testing.T) {
+	conn, err := storage.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+	conn.Close()
+	migrator, err := migrate.New(conn, testMigrations)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	if pending, err := migrator.Pending(context.Background()); err == nil {
+		t.Errorf("Pending on a closed database returned %d migrations, want an error", len(pending))
+	}
+}
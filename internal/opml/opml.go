@@ -0,0 +1,123 @@
+package opml
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// Subscription is a single feed outline. Category is the path of the folder
+// outlines it was nested in, joined with "/".
+type Subscription struct {
+	Title    string
+	URL      string
+	Category string
+}
+
+func Parse(r io.Reader) ([]Subscription, error) {
+	var doc OPML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return collect(doc.Body.Outlines, nil), nil
+}
+
+func collect(outlines []Outline, folders []string) []Subscription {
+	var subscriptions []Subscription
+	for _, outline := range outlines {
+		feedURL := strings.TrimSpace(outline.XMLURL)
+		if feedURL == "" {
+			name := strings.TrimSpace(outline.Text)
+			if name == "" {
+				name = strings.TrimSpace(outline.Title)
+			}
+			subscriptions = append(subscriptions, collect(outline.Outlines, append(folders, name))...)
+			continue
+		}
+		title := strings.TrimSpace(outline.Title)
+		if title == "" {
+			title = strings.TrimSpace(outline.Text)
+		}
+		if title == "" {
+			title = feedURL
+		}
+		subscriptions = append(subscriptions, Subscription{
+			Title:    title,
+			URL:      feedURL,
+			Category: strings.Join(folders, "/"),
+		})
+	}
+	return subscriptions
+}
+
+// Write encodes the subscriptions as an OPML 2.0 document, nesting each one
+// under folder outlines built from its Category path.
+func Write(w io.Writer, title string, subscriptions []Subscription) error {
+	doc := OPML{
+		Version: "2.0",
+		Head: Head{
+			Title:       title,
+			DateCreated: time.Now().Format(time.RFC1123Z),
+		},
+	}
+	for _, subscription := range subscriptions {
+		outlines := &doc.Body.Outlines
+		if subscription.Category != "" {
+			for _, folder := range strings.Split(subscription.Category, "/") {
+				outlines = &folderOutline(outlines, folder).Outlines
+			}
+		}
+		*outlines = append(*outlines, Outline{
+			Text:   subscription.Title,
+			Title:  subscription.Title,
+			Type:   "rss",
+			XMLURL: subscription.URL,
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func folderOutline(outlines *[]Outline, name string) *Outline {
+	for i := range *outlines {
+		if (*outlines)[i].XMLURL == "" && (*outlines)[i].Text == name {
+			return &(*outlines)[i]
+		}
+	}
+	*outlines = append(*outlines, Outline{Text: name, Title: name})
+	return &(*outlines)[len(*outlines)-1]
+}
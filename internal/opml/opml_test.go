@@ -0,0 +1,32 @@
+package opml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<opml version="2.0"><body>
+<outline text="Tech">
+  <outline text="Go" xmlUrl=" https://go.dev/blog/feed.atom "/>
+  <outline text="Blank" xmlUrl="  ">
+    <outline title="Nested" xmlUrl="https://example.com/nested.xml"/>
+  </outline>
+</outline>
+<outline xmlUrl="https://example.com/untitled.xml"/>
+</body></opml>`
+	subscriptions, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []Subscription{
+		{Title: "Go", URL: "https://go.dev/blog/feed.atom", Category: "Tech"},
+		{Title: "Nested", URL: "https://example.com/nested.xml", Category: "Tech/Blank"},
+		{Title: "https://example.com/untitled.xml", URL: "https://example.com/untitled.xml"},
+	}
+	if !reflect.DeepEqual(subscriptions, want) {
+		t.Errorf("Parse returned %+v, want %+v", subscriptions, want)
+	}
+}
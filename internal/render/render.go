@@ -0,0 +1,143 @@
+// Package render writes listing command output as a table, JSON or CSV.
+//
+// Rows are passed as a slice of structs. The json tag of each field is its
+// column name in every format, so a row struct documents the stable fields a
+// command emits. Fields tagged table:"-" are left out of the table format,
+// which is meant for reading rather than scripting.
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+type Format string
+
+const (
+	Table Format = "table"
+	JSON  Format = "json"
+	CSV   Format = "csv"
+)
+
+// maxCellWidth truncates long values such as post descriptions in tables.
+const maxCellWidth = 60
+
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(value); format {
+	case Table, JSON, CSV:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown output format %q, expected table, json or csv", value)
+}
+
+// Write renders rows, a slice of structs, to w in the given format.
+func Write(w io.Writer, format Format, rows any) error {
+	value := reflect.ValueOf(rows)
+	if value.Kind() != reflect.Slice || value.Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("render: rows must be a slice of structs, got %T", rows)
+	}
+	switch format {
+	case JSON:
+		if value.IsNil() {
+			rows = reflect.MakeSlice(value.Type(), 0, 0).Interface()
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	case CSV:
+		columns := columnsOf(value.Type().Elem(), false)
+		writer := csv.NewWriter(w)
+		if err := writer.Write(headers(columns)); err != nil {
+			return err
+		}
+		for i := 0; i < value.Len(); i++ {
+			if err := writer.Write(cells(value.Index(i), columns, false)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case Table:
+		columns := columnsOf(value.Type().Elem(), true)
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.ToUpper(strings.Join(headers(columns), "\t")))
+		for i := 0; i < value.Len(); i++ {
+			fmt.Fprintln(writer, strings.Join(cells(value.Index(i), columns, true), "\t"))
+		}
+		return writer.Flush()
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+type column struct {
+	name  string
+	index int
+}
+
+func columnsOf(rowType reflect.Type, table bool) []column {
+	var columns []column
+	for i := 0; i < rowType.NumField(); i++ {
+		field := rowType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (table && field.Tag.Get("table") == "-") {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, column{name: name, index: i})
+	}
+	return columns
+}
+
+func headers(columns []column) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+	return names
+}
+
+func cells(row reflect.Value, columns []column, table bool) []string {
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		cell := formatValue(row.Field(column.index))
+		if table {
+			cell = truncate(strings.Join(strings.Fields(cell), " "))
+		}
+		values[i] = cell
+	}
+	return values
+}
+
+// formatValue renders a cell the same way encoding/json would, minus quoting,
+// so CSV and JSON output agree. Nil pointers become empty cells.
+func formatValue(value reflect.Value) string {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	if t, ok := value.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+func truncate(cell string) string {
+	if utf8.RuneCountInString(cell) <= maxCellWidth {
+		return cell
+	}
+	return string([]rune(cell)[:maxCellWidth-3]) + "..."
+}
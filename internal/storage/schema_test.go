@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/panaiotuzunov/gator/internal/migrate"
+	postgresschema "github.com/panaiotuzunov/gator/sql/schema"
+)
+
+// postgresOnlyColumns exist only in the PostgreSQL schema. SQLite keeps the
+// search index in the posts_search FTS5 table instead.
+var postgresOnlyColumns = map[string][]string{
+	"posts": {"search_vector"},
+}
+
+// TestSchemasMatch checks that the PostgreSQL and SQLite migrations end up
+// with the same tables and columns, so a change to one is not forgotten in
+// the other. PostgreSQL is not available to tests, so its schema is worked
+// out from the migrations' CREATE TABLE and ALTER TABLE statements, while the
+// SQLite schema is read from a migrated database.
+func TestSchemasMatch(t *testing.T) {
+	migrations, err := migrate.Load(postgresschema.FS)
+	if err != nil {
+		t.Fatalf("loading PostgreSQL migrations: %v", err)
+	}
+	postgres := make(map[string][]string)
+	for _, migration := range migrations {
+		applyToSchema(postgres, migration.Up)
+	}
+	for table, columns := range postgresOnlyColumns {
+		postgres[table] = slices.DeleteFunc(postgres[table], func(column string) bool {
+			return slices.Contains(columns, column)
+		})
+	}
+
+	sqlite := sqliteSchema(t)
+	for table, columns := range postgres {
+		slices.Sort(columns)
+		if other, ok := sqlite[table]; !ok {
+			t.Errorf("table %s exists only in the PostgreSQL schema", table)
+		} else if !slices.Equal(columns, other) {
+			t.Errorf("table %s has columns %v in PostgreSQL but %v in SQLite", table, columns, other)
+		}
+	}
+	for table := range sqlite {
+		if _, ok := postgres[table]; !ok {
+			t.Errorf("table %s exists only in the SQLite schema", table)
+		}
+	}
+}
+
+// sqliteSchema returns the sorted columns of every ordinary table in a
+// migrated SQLite database.
+func sqliteSchema(t *testing.T) map[string][]string {
+	t.Helper()
+	db, err := OpenInMemory(context.Background())
+	if err != nil {
+		t.Fatalf("opening in-memory database: %v", err)
+	}
+	defer db.Conn.Close()
+	rows, err := db.Conn.Query(`SELECT t.name, c.name
+FROM pragma_table_list AS t, pragma_table_info(t.name) AS c
+WHERE t.schema = 'main' AND t.type = 'table'
+    AND t.name NOT LIKE 'sqlite_%' AND t.name <> 'schema_migrations'
+ORDER BY t.name, c.name`)
+	if err != nil {
+		t.Fatalf("listing SQLite columns: %v", err)
+	}
+	defer rows.Close()
+	schema := make(map[string][]string)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			t.Fatalf("scanning SQLite columns: %v", err)
+		}
+		schema[table] = append(schema[table], column)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("listing SQLite columns: %v", err)
+	}
+	return schema
+}
+
+var (
+	sqlComment  = regexp.MustCompile(`--[^\n]*`)
+	createTable = regexp.MustCompile(`(?is)^CREATE TABLE (?:IF NOT EXISTS )?(\w+)\s*\((.*)\)$`)
+	alterTable  = regexp.MustCompile(`(?is)^ALTER TABLE (?:IF EXISTS )?(?:ONLY )?(\w+)\s+(.*)$`)
+	dropTable   = regexp.MustCompile(`(?is)^DROP TABLE (?:IF EXISTS )?(\w+)`)
+	leadingWord = regexp.MustCompile(`^\w+`)
+)
+
+// tableConstraints start the entries of a CREATE TABLE that are not columns.
+var tableConstraints = []string{"CONSTRAINT", "PRIMARY", "UNIQUE", "FOREIGN", "CHECK", "EXCLUDE"}
+
+// applyToSchema updates schema, a map from table to column names, with the
+// table and column changes made by the statements in sql. Other statements,
+// such as indexes and data changes, do not affect the columns and are
+// skipped.
+func applyToSchema(schema map[string][]string, sql string) {
+	for _, statement := range splitTopLevel(sqlComment.ReplaceAllString(sql, ""), ';') {
+		statement = strings.Join(strings.Fields(statement), " ")
+		if match := createTable.FindStringSubmatch(statement); match != nil {
+			var columns []string
+			for _, definition := range splitTopLevel(match[2], ',') {
+				name := leadingWord.FindString(definition)
+				if !slices.Contains(tableConstraints, strings.ToUpper(name)) {
+					columns = append(columns, name)
+				}
+			}
+			schema[match[1]] = columns
+		} else if match := alterTable.FindStringSubmatch(statement); match != nil {
+			for _, action := range splitTopLevel(match[2], ',') {
+				alterColumns(schema, match[1], strings.Fields(action))
+			}
+		} else if match := dropTable.FindStringSubmatch(statement); match != nil {
+			delete(schema, match[1])
+		}
+	}
+}
+
+func alterColumns(schema map[string][]string, table string, words []string) {
+	upper := make([]string, len(words))
+	for i, word := range words {
+		upper[i] = strings.ToUpper(word)
+	}
+	switch {
+	case len(words) >= 2 && upper[0] == "ADD" && !slices.Contains(tableConstraints, upper[1]):
+		column := words[1]
+		if upper[1] == "COLUMN" && len(words) >= 3 {
+			column = words[2]
+		}
+		schema[table] = append(schema[table], column)
+	case len(words) >= 3 && upper[0] == "DROP" && upper[1] == "COLUMN":
+		schema[table] = slices.DeleteFunc(schema[table], func(column string) bool { return column == words[2] })
+	case len(words) >= 5 && upper[0] == "RENAME" && upper[1] == "COLUMN" && upper[3] == "TO":
+		if i := slices.Index(schema[table], words[2]); i >= 0 {
+			schema[table][i] = words[4]
+		}
+	case len(words) >= 3 && upper[0] == "RENAME" && upper[1] == "TO":
+		schema[words[2]] = schema[table]
+		delete(schema, table)
+	}
+}
+
+// splitTopLevel splits s at sep, ignoring separators inside parentheses or
+// quotes.
+func splitTopLevel(s string, sep rune) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, s[start:])
+	var nonEmpty []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return nonEmpty
+}
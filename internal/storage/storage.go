@@ -0,0 +1,127 @@
+// Package storage opens the database named by db_url with the engine it
+// calls for: PostgreSQL for postgres:// URLs, SQLite for sqlite:// URLs and
+// plain file paths.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"net/url"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/panaiotuzunov/gator/internal/database"
+	"github.com/panaiotuzunov/gator/internal/database/sqlite"
+	"github.com/panaiotuzunov/gator/internal/migrate"
+	postgresschema "github.com/panaiotuzunov/gator/sql/schema"
+	sqliteschema "github.com/panaiotuzunov/gator/sql/sqlite/schema"
+	moderncsqlite "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type Engine string
+
+const (
+	Postgres Engine = "postgres"
+	SQLite   Engine = "sqlite"
+)
+
+// DB is an open database together with the queries and migrations for its
+// engine.
+type DB struct {
+	Engine     Engine
+	Conn       *sql.DB
+	Store      database.Store
+	Migrations fs.FS
+}
+
+// Open opens dbURL. Like sql.Open it does not connect, so a bad PostgreSQL
+// URL is only reported by the first query.
+func Open(dbURL string) (*DB, error) {
+	path, ok := SQLitePath(dbURL)
+	if !ok {
+		conn, err := sql.Open("postgres", dbURL)
+		if err != nil {
+			return nil, err
+		}
+		return &DB{Engine: Postgres, Conn: conn, Store: database.New(conn), Migrations: postgresschema.FS}, nil
+	}
+	conn, err := OpenSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{Engine: SQLite, Conn: conn, Store: sqlite.NewStore(conn), Migrations: sqliteschema.FS}, nil
+}
+
+// OpenInMemory returns a private, fully migrated SQLite database that lives
+// only as long as the returned connection. Tests use it in place of a real
+// database. There is deliberately no hand-written in-memory Store: running
+// the real SQLite queries keeps tests honest about constraints, ordering and
+// transactions, which a fake would have to reimplement.
+func OpenInMemory(ctx context.Context) (*DB, error) {
+	conn, err := OpenSQLite(":memory:")
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := migrate.New(conn, sqliteschema.FS)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &DB{Engine: SQLite, Conn: conn, Store: sqlite.NewStore(conn), Migrations: sqliteschema.FS}, nil
+}
+
+// OpenSQLite opens the SQLite database at path, or a private in-memory
+// database for ":memory:", with foreign keys enforced.
+func OpenSQLite(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Set("_time_format", "sqlite")
+	if path != ":memory:" {
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+	conn, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time, and every connection to
+	// ":memory:" would otherwise get its own empty database.
+	conn.SetMaxOpenConns(1)
+	return conn, nil
+}
+
+// SQLitePath reports whether dbURL names a SQLite database, and if so the
+// file path to open.
+func SQLitePath(dbURL string) (string, bool) {
+	if path, ok := strings.CutPrefix(dbURL, "sqlite://"); ok {
+		return path, true
+	}
+	if path, ok := strings.CutPrefix(dbURL, "sqlite:"); ok {
+		return path, true
+	}
+	if strings.Contains(dbURL, "://") || strings.Contains(dbURL, "=") {
+		return "", false
+	}
+	return dbURL, dbURL != ""
+}
+
+// IsUniqueViolation reports whether err is a unique constraint violation
+// from either engine.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var sqliteErr *moderncsqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
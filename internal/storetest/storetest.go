@@ -0,0 +1,93 @@
+// Package storetest provides the database fixtures shared by the tests of the
+// command handlers and of the store implementations.
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/panaiotuzunov/gator/internal/database"
+	"github.com/panaiotuzunov/gator/internal/storage"
+)
+
+// Open returns a fresh, fully migrated in-memory database that is closed
+// when the test ends.
+func Open(t testing.TB) *storage.DB {
+	t.Helper()
+	db, err := storage.OpenInMemory(context.Background())
+	if err != nil {
+		t.Fatalf("opening in-memory database: %v", err)
+	}
+	t.Cleanup(func() { db.Conn.Close() })
+	return db
+}
+
+// CreateUser creates a user without a password.
+func CreateUser(t testing.TB, store database.Store, name string) database.User {
+	t.Helper()
+	user, err := store.CreateUser(context.Background(), database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Name:      name,
+	})
+	if err != nil {
+		t.Fatalf("creating user %s: %v", name, err)
+	}
+	return user
+}
+
+// CreateFeed creates a feed owned by user without fetching it.
+func CreateFeed(t testing.TB, store database.Store, user database.User, name, url string) database.Feed {
+	t.Helper()
+	feed, err := store.CreateFeed(context.Background(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Name:      name,
+		Url:       url,
+		UserID:    user.ID,
+	})
+	if err != nil {
+		t.Fatalf("creating feed %s: %v", name, err)
+	}
+	return feed
+}
+
+func Follow(t testing.TB, store database.Store, user database.User, feed database.Feed) database.CreateFeedFollowRow {
+	t.Helper()
+	row, err := store.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+	})
+	if err != nil {
+		t.Fatalf("following %s: %v", feed.Name, err)
+	}
+	return row
+}
+
+// UpsertPost stores a post in feed. Its URL is derived from guid and its
+// description from title.
+func UpsertPost(t testing.TB, store database.Store, feed database.Feed, guid, title string, publishedAt time.Time) database.UpsertPostRow {
+	t.Helper()
+	post, err := store.UpsertPost(context.Background(), database.UpsertPostParams{
+		ID:          uuid.New(),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		Title:       title,
+		Url:         "https://example.com/" + guid,
+		Description: "About " + title,
+		PublishedAt: publishedAt,
+		FeedID:      feed.ID,
+		Guid:        guid,
+	})
+	if err != nil {
+		t.Fatalf("upserting post %s: %v", guid, err)
+	}
+	return post
+}
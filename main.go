@@ -1,46 +1,266 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 
-	_ "github.com/lib/pq"
 	"github.com/panaiotuzunov/gator/internal/config"
-	"github.com/panaiotuzunov/gator/internal/database"
+	"github.com/panaiotuzunov/gator/internal/migrate"
+	"github.com/panaiotuzunov/gator/internal/render"
+	"github.com/panaiotuzunov/gator/internal/storage"
 )
 
 func main() {
-	configStruct, err := config.Read()
-	if err != nil {
-		fmt.Printf("cound not read config file - %v\n", err)
+	cmds := registerCommands()
+	global := flag.NewFlagSet("gator", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+	output := global.String("output", string(render.Table), "output format - table, json or csv")
+	if err := global.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			cmds.printHelp(os.Stdout)
+			os.Exit(0)
+		}
+		fmt.Printf("error: %v\n", err)
 		os.Exit(1)
 	}
-	stateStruct := state{cfg: &configStruct}
-	cmds := &commands{
-		list: make(map[string]func(*state, command) error),
-	}
-	db, err := sql.Open("postgres", configStruct.DbUrl)
+	format, err := render.ParseFormat(*output)
 	if err != nil {
-		fmt.Printf("Could not connect to SQL DB - %v\n", err)
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
 	}
-	stateStruct.db = database.New(db)
-	cmds.register("login", handlerLogin)
-	cmds.register("register", handlerRegister)
-	cmds.register("reset", handlerReset)
-	cmds.register("users", handlerUsers)
-	cmds.register("agg", handlerAgg)
-	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
-	cmds.register("feeds", handlerGetFeeds)
-	cmds.register("follow", middlewareLoggedIn(handlerFollow))
-	cmds.register("following", middlewareLoggedIn(handlerFollowing))
-	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
-	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
-	cmd := command{name: os.Args[1], args: os.Args[2:]}
-	err = cmds.run(&stateStruct, cmd)
+	if global.NArg() == 0 {
+		cmds.printHelp(os.Stderr)
+		os.Exit(1)
+	}
+	cmd := command{name: global.Arg(0), args: global.Args()[1:], output: format}
+	err = cmds.run(cmd, loadState)
 	if err != nil {
 		fmt.Printf("running command %v failed with %v\n", cmd.name, err)
 		os.Exit(1)
 	}
 	os.Exit(0)
 }
+
+// loadState reads the config and opens the database. With checkSchema it
+// refuses to continue while embedded migrations are still pending, since the
+// queries would fail against the older schema.
+func loadState(checkSchema bool) (*state, error) {
+	configStruct, err := config.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cound not read config file - %v", err)
+	}
+	db, err := storage.Open(configStruct.DbUrl)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to SQL DB - %v", err)
+	}
+	if checkSchema {
+		migrator, err := migrate.New(db.Conn, db.Migrations)
+		if err != nil {
+			return nil, err
+		}
+		pending, err := migrator.Pending(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("could not check the database schema - %v", err)
+		}
+		if len(pending) > 0 {
+			return nil, fmt.Errorf("the database schema is %d migration(s) behind this version of gator - run 'gator migrate up'", len(pending))
+		}
+	}
+	return &state{db: db.Store, conn: db.Conn, migrations: db.Migrations, cfg: &configStruct}, nil
+}
+
+func registerCommands() *commands {
+	cmds := newCommands()
+	cmds.register(commandSpec{
+		name:        "help",
+		description: "Show the list of commands, or details on one command",
+		optional:    []string{"command"},
+		handler:     cmds.handlerHelp,
+		stateless:   true,
+	})
+	cmds.register(commandSpec{
+		name:        "migrate",
+		description: "Apply (up) or roll back (down) schema migrations, or show their status",
+		args:        []string{"up|down|status"},
+		listing:     true,
+		handler:     handlerMigrate,
+		anySchema:   true,
+	})
+	cmds.register(commandSpec{
+		name:        "login",
+		description: "Log in as an existing user",
+		args:        []string{"username"},
+		handler:     handlerLogin,
+	})
+	cmds.register(commandSpec{
+		name:        "register",
+		description: "Create a new user and log in as them",
+		args:        []string{"username"},
+		handler:     handlerRegister,
+	})
+	cmds.register(commandSpec{
+		name:        "logout",
+		description: "Log out and revoke the session token",
+		handler:     handlerLogout,
+	})
+	cmds.register(commandSpec{
+		name:        "apitoken",
+		description: "Create a token for the REST API",
+		optional:    []string{"lifetime"},
+		handler:     middlewareLoggedIn(handlerApiToken),
+	})
+	cmds.register(commandSpec{
+		name:        "setpassword",
+		description: "Change your password and revoke your other sessions",
+		args:        []string{"username"},
+		handler:     middlewareLoggedIn(handlerSetPassword),
+	})
+	cmds.register(commandSpec{
+		name:        "hashpassword",
+		description: "Print a password hash for an administrator to store for an account without a password",
+		handler:     handlerHashPassword,
+		stateless:   true,
+	})
+	cmds.register(commandSpec{
+		name:        "reset",
+		description: "Delete all users and their data",
+		handler:     handlerReset,
+	})
+	cmds.register(commandSpec{
+		name:        "users",
+		description: "List all users",
+		listing:     true,
+		handler:     handlerUsers,
+	})
+	cmds.register(commandSpec{
+		name:        "agg",
+		description: "Fetch due feeds continuously",
+		args:        []string{"time_between_reqs"},
+		optional:    []string{"workers"},
+		handler:     handlerAgg,
+	})
+	cmds.register(commandSpec{
+		name:        "addfeed",
+		description: "Add a feed, or one found on a website, and follow it",
+		args:        []string{"name", "url"},
+		handler:     middlewareLoggedIn(handlerAddFeed),
+	})
+	cmds.register(commandSpec{
+		name:        "feeds",
+		description: "List all feeds",
+		listing:     true,
+		handler:     handlerGetFeeds,
+	})
+	cmds.register(commandSpec{
+		name:        "setinterval",
+		description: "Set how often a feed is fetched",
+		args:        []string{"url", "interval"},
+		handler:     handlerSetInterval,
+	})
+	cmds.register(commandSpec{
+		name:        "feederrors",
+		description: "List feeds that are failing or disabled",
+		listing:     true,
+		handler:     handlerFeedErrors,
+	})
+	cmds.register(commandSpec{
+		name:        "enablefeed",
+		description: "Re-enable a feed disabled after repeated failures",
+		args:        []string{"url"},
+		handler:     handlerEnableFeed,
+	})
+	cmds.register(commandSpec{
+		name:        "follow",
+		description: "Follow an existing feed",
+		args:        []string{"url"},
+		handler:     middlewareLoggedIn(handlerFollow),
+	})
+	cmds.register(commandSpec{
+		name:        "following",
+		description: "List the feeds you follow",
+		listing:     true,
+		handler:     middlewareLoggedIn(handlerFollowing),
+	})
+	cmds.register(commandSpec{
+		name:        "unfollow",
+		description: "Stop following a feed",
+		args:        []string{"url"},
+		handler:     middlewareLoggedIn(handlerUnfollow),
+	})
+	cmds.register(commandSpec{
+		name:        "browse",
+		description: "Show posts from the feeds you follow",
+		optional:    []string{"limit"},
+		flags:       browseFlags,
+		listing:     true,
+		handler:     middlewareLoggedIn(handlerBrowse),
+	})
+	cmds.register(commandSpec{
+		name:        "search",
+		description: "Search posts from the feeds you follow",
+		args:        []string{"query"},
+		variadic:    true,
+		listing:     true,
+		handler:     middlewareLoggedIn(handlerSearch),
+	})
+	cmds.register(commandSpec{
+		name:        "read",
+		description: "Mark a post as read",
+		args:        []string{"post-id"},
+		handler:     middlewareLoggedIn(handlerRead),
+	})
+	cmds.register(commandSpec{
+		name:        "unread",
+		description: "Mark a post as unread",
+		args:        []string{"post-id"},
+		handler:     middlewareLoggedIn(handlerUnread),
+	})
+	cmds.register(commandSpec{
+		name:        "markall",
+		description: "Mark every post, or every post in one feed, as read",
+		args:        []string{"read"},
+		optional:    []string{"feed-url"},
+		handler:     middlewareLoggedIn(handlerMarkAll),
+	})
+	cmds.register(commandSpec{
+		name:        "star",
+		description: "Star a post so it is kept",
+		args:        []string{"post-id"},
+		handler:     middlewareLoggedIn(handlerStar),
+	})
+	cmds.register(commandSpec{
+		name:        "unstar",
+		description: "Remove a star",
+		args:        []string{"id"},
+		handler:     middlewareLoggedIn(handlerUnstar),
+	})
+	cmds.register(commandSpec{
+		name:        "starred",
+		description: "List your starred posts",
+		listing:     true,
+		handler:     middlewareLoggedIn(handlerStarred),
+	})
+	cmds.register(commandSpec{
+		name:        "import",
+		description: "Import subscriptions from an OPML file",
+		args:        []string{"file"},
+		handler:     middlewareLoggedIn(handlerImport),
+	})
+	cmds.register(commandSpec{
+		name:        "export",
+		description: "Export subscriptions as OPML to a file or stdout",
+		optional:    []string{"file"},
+		handler:     middlewareLoggedIn(handlerExport),
+	})
+	cmds.register(commandSpec{
+		name:        "serve",
+		description: "Serve the REST API",
+		args:        []string{"addr"},
+		handler:     handlerServe,
+	})
+	return cmds
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/panaiotuzunov/gator/internal/render"
+)
+
+// commandSpec describes a command: what it is called, the positional
+// arguments and flags it accepts, and the handler that runs it. The registry
+// validates arguments against the spec before the handler is called, so
+// handlers can index cmd.args without checking its length.
+type commandSpec struct {
+	name        string
+	description string
+	// args are required positional arguments, optional follow them.
+	args     []string
+	optional []string
+	// variadic allows any number of arguments after the required ones.
+	variadic bool
+	// flags declares the command's flags on its flag set.
+	flags func(*flag.FlagSet)
+	// listing commands accept --output to choose how their rows are rendered.
+	listing bool
+	handler func(*state, command) error
+	// stateless commands run without reading the config or opening the database.
+	stateless bool
+	// anySchema commands run even when the database schema is behind the binary.
+	anySchema bool
+}
+
+type command struct {
+	name  string
+	args  []string
+	flags *flag.FlagSet
+	// output is the format listing commands render their rows in.
+	output render.Format
+}
+
+// flagString returns the value of a string flag declared by the command spec.
+func (cmd command) flagString(name string) string {
+	return cmd.flags.Lookup(name).Value.String()
+}
+
+// flagBool returns the value of a boolean flag declared by the command spec.
+func (cmd command) flagBool(name string) bool {
+	value, _ := strconv.ParseBool(cmd.flags.Lookup(name).Value.String())
+	return value
+}
+
+// writeRows renders the rows of a listing command to stdout. In table mode an
+// empty listing prints the empty message instead of a bare header.
+func writeRows[T any](cmd command, rows []T, empty string) error {
+	if len(rows) == 0 && cmd.output == render.Table {
+		fmt.Println(empty)
+		return nil
+	}
+	return render.Write(os.Stdout, cmd.output, rows)
+}
+
+type commands struct {
+	list  map[string]commandSpec
+	order []string
+}
+
+func newCommands() *commands {
+	return &commands{list: make(map[string]commandSpec)}
+}
+
+func (c *commands) register(spec commandSpec) {
+	c.list[spec.name] = spec
+	c.order = append(c.order, spec.name)
+}
+
+// run validates cmd against its spec and calls the handler. loadState is only
+// called once the arguments are known to be valid, so help and usage errors
+// never need a config file or a database.
+func (c *commands) run(cmd command, loadState func(checkSchema bool) (*state, error)) error {
+	spec, ok := c.list[cmd.name]
+	if !ok {
+		if suggestion := c.suggest(cmd.name); suggestion != "" {
+			return fmt.Errorf("error: unknown command %q, did you mean %q?", cmd.name, suggestion)
+		}
+		return fmt.Errorf("error: unknown command %q, run 'gator help' for a list of commands", cmd.name)
+	}
+	flags := spec.flagSet()
+	if spec.listing {
+		flags.String("output", string(cmd.output), "output format - table, json or csv")
+	}
+	args, err := spec.parseArgs(flags, cmd.args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			spec.printHelp(os.Stdout)
+			return nil
+		}
+		return fmt.Errorf("error: %v\n%s", err, spec.usage())
+	}
+	if len(args) < len(spec.args) || (!spec.variadic && len(args) > len(spec.args)+len(spec.optional)) {
+		return fmt.Errorf("error: wrong number of arguments\n%s", spec.usage())
+	}
+	if spec.listing {
+		format, err := render.ParseFormat(flags.Lookup("output").Value.String())
+		if err != nil {
+			return fmt.Errorf("error: %v", err)
+		}
+		cmd.output = format
+	}
+	s := &state{}
+	if !spec.stateless {
+		s, err = loadState(!spec.anySchema)
+		if err != nil {
+			return err
+		}
+	}
+	return spec.handler(s, command{name: cmd.name, args: args, flags: flags, output: cmd.output})
+}
+
+// parseArgs parses the flags and returns the positional arguments. Flags may
+// follow the arguments, except for commands that take free text, such as
+// "search pgvector -draft", or that have no flags of their own. Their flags
+// have to come first, so an argument that starts with "-" is kept as is.
+func (spec commandSpec) parseArgs(flags *flag.FlagSet, arguments []string) ([]string, error) {
+	if spec.variadic || (spec.flags == nil && !spec.listing) {
+		if err := flags.Parse(arguments); err != nil {
+			return nil, err
+		}
+		return flags.Args(), nil
+	}
+	return parseInterspersed(flags, arguments)
+}
+
+// parseInterspersed parses flags wherever they appear among the arguments, so
+// "browse 5 --unread" works like "browse --unread 5". The flag package alone
+// stops at the first positional argument. Everything after "--" is
+// positional. It returns the positional arguments in order.
+func parseInterspersed(flags *flag.FlagSet, arguments []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(arguments); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if consumed := arguments[:len(arguments)-len(rest)]; len(consumed) > 0 && consumed[len(consumed)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		arguments = rest[1:]
+	}
+}
+
+func (spec commandSpec) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet(spec.name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	if spec.flags != nil {
+		spec.flags(flags)
+	}
+	return flags
+}
+
+func (spec commandSpec) usage() string {
+	parts := []string{"usage: gator", spec.name}
+	if spec.flags != nil || spec.listing {
+		parts = append(parts, "[flags]")
+	}
+	for _, arg := range spec.args {
+		parts = append(parts, "<"+arg+">")
+	}
+	for _, arg := range spec.optional {
+		parts = append(parts, "["+arg+"]")
+	}
+	if spec.variadic {
+		parts[len(parts)-1] += "..."
+	}
+	return strings.Join(parts, " ")
+}
+
+func (spec commandSpec) printHelp(w io.Writer) {
+	fmt.Fprintln(w, spec.usage())
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, spec.description)
+	if spec.flags != nil || spec.listing {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		flags := spec.flagSet()
+		if spec.listing {
+			flags.String("output", string(render.Table), "output format - table, json or csv")
+		}
+		flags.SetOutput(w)
+		flags.PrintDefaults()
+	}
+}
+
+func (c *commands) printHelp(w io.Writer) {
+	fmt.Fprintln(w, "usage: gator [--output table|json|csv] <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := append([]string(nil), c.order...)
+	sort.Strings(names)
+	width := 0
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, name, c.list[name].description)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'gator help <command>' or 'gator <command> --help' for details on a command.")
+}
+
+func (c *commands) handlerHelp(s *state, cmd command) error {
+	if len(cmd.args) == 0 {
+		c.printHelp(os.Stdout)
+		return nil
+	}
+	spec, ok := c.list[cmd.args[0]]
+	if !ok {
+		if suggestion := c.suggest(cmd.args[0]); suggestion != "" {
+			return fmt.Errorf("error: unknown command %q, did you mean %q?", cmd.args[0], suggestion)
+		}
+		return fmt.Errorf("error: unknown command %q", cmd.args[0])
+	}
+	spec.printHelp(os.Stdout)
+	return nil
+}
+
+// suggest returns the registered command closest to name, or an empty string
+// if none is close enough to be a likely typo.
+func (c *commands) suggest(name string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range c.order {
+		distance := levenshtein(name, candidate)
+		if distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,20 @@
+-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostUnread :exec
+DELETE FROM post_reads
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: MarkAllPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT sqlc.arg('user_id')::uuid, posts.id, sqlc.arg('read_at')::timestamp
+FROM posts
+WHERE posts.feed_id IN (
+    SELECT feed_id
+    FROM feed_follows
+    WHERE user_id = sqlc.arg('user_id')
+)
+AND (sqlc.narg('feed_id')::uuid IS NULL OR posts.feed_id = sqlc.narg('feed_id'))
+ON CONFLICT (user_id, post_id) DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- name: CreatePostRevision :exec
+INSERT INTO post_revisions (id, created_at, post_id, title, description)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
\ No newline at end of file
@@ -1,23 +1,100 @@
--- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES (
-    $1,
-    $2,
-    $3,
-    $4,
-    $5,
-    $6,
-    $7,
-    $8
+-- name: UpsertPost :one
+WITH previous AS (
+    SELECT id, title, description FROM posts
+    WHERE feed_id = $8 AND guid = $9
+), upserted AS (
+    INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, guid)
+    VALUES (
+        $1,
+        $2,
+        $3,
+        $4,
+        $5,
+        $6,
+        $7,
+        $8,
+        $9
+    )
+    ON CONFLICT (feed_id, guid) DO UPDATE
+    SET title = EXCLUDED.title,
+        url = EXCLUDED.url,
+        description = EXCLUDED.description,
+        updated_at = EXCLUDED.updated_at
+    WHERE posts.title <> EXCLUDED.title OR posts.description <> EXCLUDED.description
+    RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, guid
 )
-RETURNING *;
+SELECT
+    upserted.*,
+    previous.title AS previous_title,
+    previous.description AS previous_description
+FROM upserted
+LEFT JOIN previous ON upserted.id = previous.id;
+
+-- name: GetPostGuidsByUrl :many
+SELECT guid FROM posts
+WHERE feed_id = $1 AND url = $2;
 
 -- name: GetPostsForUser :many
-SELECT * FROM posts 
-WHERE feed_id IN (
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.guid,
+    feeds.name AS feed_name,
+    EXISTS (
+        SELECT 1 FROM post_revisions
+        WHERE post_revisions.post_id = posts.id
+    ) AS updated,
+    EXISTS (
+        SELECT 1 FROM post_reads
+        WHERE post_reads.post_id = posts.id AND post_reads.user_id = sqlc.arg('user_id')
+    ) AS is_read,
+    EXISTS (
+        SELECT 1 FROM post_stars
+        WHERE post_stars.post_id = posts.id AND post_stars.user_id = sqlc.arg('user_id')
+    ) AS starred
+FROM posts
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE posts.feed_id IN (
     SELECT feed_id 
     FROM feed_follows 
-    WHERE user_id = $1
+    WHERE user_id = sqlc.arg('user_id')
+)
+AND (NOT sqlc.arg('unread_only')::boolean OR NOT EXISTS (
+    SELECT 1 FROM post_reads
+    WHERE post_reads.post_id = posts.id AND post_reads.user_id = sqlc.arg('user_id')
+))
+AND (sqlc.narg('feed')::text IS NULL OR feeds.name = sqlc.narg('feed') OR feeds.url = sqlc.narg('feed'))
+AND (sqlc.narg('since')::timestamp IS NULL OR posts.published_at >= sqlc.narg('since'))
+AND (sqlc.narg('before')::timestamp IS NULL OR posts.published_at < sqlc.narg('before'))
+AND (sqlc.narg('keyword')::text IS NULL
+    OR posts.title ILIKE '%' || sqlc.narg('keyword') || '%' ESCAPE '\'
+    OR posts.description ILIKE '%' || sqlc.narg('keyword') || '%' ESCAPE '\')
+AND (sqlc.narg('after_published_at')::timestamp IS NULL
+    OR (sqlc.arg('sort_order')::text = 'oldest' AND (posts.published_at, posts.id) > (sqlc.narg('after_published_at'), sqlc.narg('after_id')::uuid))
+    OR (sqlc.arg('sort_order')::text <> 'oldest' AND (posts.published_at, posts.id) < (sqlc.narg('after_published_at'), sqlc.narg('after_id')::uuid)))
+ORDER BY
+    CASE WHEN sqlc.arg('sort_order')::text = 'feed' THEN feeds.name END ASC,
+    CASE WHEN sqlc.arg('sort_order')::text = 'oldest' THEN posts.published_at END ASC,
+    CASE WHEN sqlc.arg('sort_order')::text = 'oldest' THEN posts.id END ASC,
+    posts.published_at DESC,
+    posts.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: SearchPostsForUser :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, query) AS rank,
+    ts_headline('english', posts.description, query, 'StartSel=**, StopSel=**, MaxFragments=2, MaxWords=30') AS snippet
+FROM posts
+INNER JOIN feeds ON posts.feed_id = feeds.id
+CROSS JOIN websearch_to_tsquery('english', sqlc.arg('query')::text) AS query
+WHERE posts.feed_id IN (
+    SELECT feed_id
+    FROM feed_follows
+    WHERE user_id = sqlc.arg('user_id')
 )
-ORDER BY published_at DESC
-LIMIT $2;
\ No newline at end of file
+AND posts.search_vector @@ query
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT sqlc.arg('limit');
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD fetch_interval_seconds INTEGER,
+ADD next_fetch_at TIMESTAMP,
+ADD refresh_hints TEXT;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN fetch_interval_seconds,
+DROP COLUMN next_fetch_at,
+DROP COLUMN refresh_hints;
\ No newline at end of file
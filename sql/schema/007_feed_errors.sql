@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD consecutive_errors INTEGER NOT NULL DEFAULT 0,
+ADD last_error TEXT,
+ADD last_status_code INTEGER,
+ADD last_succeeded_at TIMESTAMP,
+ADD disabled_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN consecutive_errors,
+DROP COLUMN last_error,
+DROP COLUMN last_status_code,
+DROP COLUMN last_succeeded_at,
+DROP COLUMN disabled_at;
\ No newline at end of file
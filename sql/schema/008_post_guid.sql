@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE posts
+ADD guid TEXT;
+
+UPDATE posts SET guid = url;
+
+ALTER TABLE posts
+ALTER COLUMN guid SET NOT NULL,
+DROP CONSTRAINT posts_url_key,
+ADD CONSTRAINT posts_feed_id_guid_key UNIQUE(feed_id, guid);
+
+-- +goose Down
+-- Several feeds may have stored the same post URL since, and only one copy
+-- of each can survive the UNIQUE(url) constraint. Keep the oldest.
+DELETE FROM posts
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY url ORDER BY created_at, id) AS copy
+        FROM posts
+    ) copies
+    WHERE copy > 1
+);
+
+ALTER TABLE posts
+DROP CONSTRAINT posts_feed_id_guid_key,
+ADD CONSTRAINT posts_url_key UNIQUE(url),
+DROP COLUMN guid;
\ No newline at end of file
@@ -0,0 +1,28 @@
+-- name: CreateApiToken :one
+INSERT INTO api_tokens (id, created_at, user_id, token_hash, expires_at)
+VALUES (
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5
+)
+RETURNING *;
+
+-- name: GetUserByApiToken :one
+SELECT users.* FROM users
+INNER JOIN api_tokens ON api_tokens.user_id = users.id
+WHERE api_tokens.token_hash = ?1 AND api_tokens.expires_at > ?2;
+
+-- name: TouchApiToken :exec
+UPDATE api_tokens
+SET last_used_at = ?1
+WHERE token_hash = ?2;
+
+-- name: DeleteApiToken :exec
+DELETE FROM api_tokens
+WHERE token_hash = ?1;
+
+-- name: DeleteApiTokensForUser :exec
+DELETE FROM api_tokens
+WHERE user_id = ?1;
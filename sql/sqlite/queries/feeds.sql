@@ -0,0 +1,94 @@
+-- name: CreateFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+VALUES (
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    ?6
+)
+RETURNING *;
+
+-- name: GetFeeds :many
+SELECT name, url, user_id FROM feeds;
+
+-- name: GetFeedByUrl :one
+SELECT * FROM feeds
+WHERE url = ?1;
+
+-- name: MarkFeedFetched :exec
+UPDATE feeds
+SET last_fetched_at = ?1, updated_at = ?1
+WHERE id = ?2;
+
+-- name: GetNextFeedToFetch :one
+-- SQLite serializes writers, so unlike PostgreSQL no row lock is needed to
+-- stop two workers claiming the same feed.
+UPDATE feeds
+SET last_fetched_at = sqlc.arg('last_fetched_at'), updated_at = sqlc.arg('last_fetched_at'), next_fetch_at = sqlc.arg('next_fetch_at')
+WHERE id = (
+    SELECT id FROM feeds
+    WHERE disabled_at IS NULL
+    AND (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg('last_fetched_at'))
+    AND (last_fetched_at IS NULL OR last_fetched_at < sqlc.arg('round_started_at'))
+    ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+    LIMIT 1
+)
+RETURNING *;
+
+-- name: UpdateFeedCache :exec
+UPDATE feeds
+SET etag = ?1, last_modified = ?2
+WHERE id = ?3;
+
+-- name: UpdateFeedRefreshHints :exec
+UPDATE feeds
+SET refresh_hints = ?1
+WHERE id = ?2;
+
+-- name: ScheduleNextFetch :exec
+UPDATE feeds
+SET next_fetch_at = ?1
+WHERE id = ?2;
+
+-- name: SetFeedFetchInterval :one
+UPDATE feeds
+SET fetch_interval_seconds = ?1, next_fetch_at = NULL, updated_at = ?2
+WHERE url = ?3
+RETURNING *;
+
+-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET consecutive_errors = 0, last_error = NULL, last_status_code = ?1, last_succeeded_at = ?2
+WHERE id = ?3;
+
+-- name: RecordFeedFailure :one
+UPDATE feeds
+SET consecutive_errors = consecutive_errors + 1, last_error = ?1, last_status_code = ?2
+WHERE id = ?3
+RETURNING *;
+
+-- name: DisableFeed :exec
+UPDATE feeds
+SET disabled_at = ?1, updated_at = ?1
+WHERE id = ?2;
+
+-- name: EnableFeed :one
+UPDATE feeds
+SET disabled_at = NULL, consecutive_errors = 0, next_fetch_at = NULL, updated_at = ?1
+WHERE url = ?2
+RETURNING *;
+
+-- name: GetFeedsWithErrors :many
+SELECT * FROM feeds
+WHERE consecutive_errors > 0 OR disabled_at IS NOT NULL
+ORDER BY consecutive_errors DESC, name ASC;
+
+-- name: GetAllFeeds :many
+SELECT * FROM feeds
+ORDER BY name ASC;
+
+-- name: DeleteFeed :execrows
+DELETE FROM feeds
+WHERE id = ?1 AND user_id = ?2;
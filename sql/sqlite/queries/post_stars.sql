@@ -0,0 +1,25 @@
+-- name: StarPost :execrows
+INSERT INTO post_stars (id, created_at, user_id, post_id, title, url, description, published_at, feed_name)
+SELECT
+    sqlc.arg('id'),
+    sqlc.arg('created_at'),
+    sqlc.arg('user_id'),
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.description,
+    posts.published_at,
+    feeds.name
+FROM posts
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE posts.id = sqlc.arg('post_id')
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: UnstarPost :execrows
+DELETE FROM post_stars
+WHERE user_id = ?1 AND (id = ?2 OR post_id = ?2);
+
+-- name: GetStarredPostsForUser :many
+SELECT * FROM post_stars
+WHERE user_id = ?1
+ORDER BY created_at DESC;
@@ -0,0 +1,95 @@
+-- name: GetPostByGuid :one
+SELECT id, title, description FROM posts
+WHERE feed_id = ?1 AND guid = ?2;
+
+-- name: GetPostGuidsByUrl :many
+SELECT guid FROM posts
+WHERE feed_id = ?1 AND url = ?2;
+
+-- name: UpsertPost :one
+-- SQLite cannot return the row as it was before an upsert, so callers that
+-- need the previous title and description read them with GetPostByGuid first.
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, guid)
+VALUES (
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    ?6,
+    ?7,
+    ?8,
+    ?9
+)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = excluded.title,
+    url = excluded.url,
+    description = excluded.description,
+    updated_at = excluded.updated_at
+WHERE posts.title <> excluded.title OR posts.description <> excluded.description
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, guid;
+
+-- name: GetPostsForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.guid,
+    feeds.name AS feed_name,
+    EXISTS (
+        SELECT 1 FROM post_revisions
+        WHERE post_revisions.post_id = posts.id
+    ) AS updated,
+    EXISTS (
+        SELECT 1 FROM post_reads
+        WHERE post_reads.post_id = posts.id AND post_reads.user_id = sqlc.arg('user_id')
+    ) AS is_read,
+    EXISTS (
+        SELECT 1 FROM post_stars
+        WHERE post_stars.post_id = posts.id AND post_stars.user_id = sqlc.arg('user_id')
+    ) AS starred
+FROM posts
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE posts.feed_id IN (
+    SELECT feed_id
+    FROM feed_follows
+    WHERE user_id = sqlc.arg('user_id')
+)
+AND (NOT CAST(sqlc.arg('unread_only') AS BOOLEAN) OR NOT EXISTS (
+    SELECT 1 FROM post_reads
+    WHERE post_reads.post_id = posts.id AND post_reads.user_id = sqlc.arg('user_id')
+))
+AND (CAST(sqlc.narg('feed') AS TEXT) IS NULL OR feeds.name = sqlc.narg('feed') OR feeds.url = sqlc.narg('feed'))
+AND (CAST(sqlc.narg('since') AS TIMESTAMP) IS NULL OR posts.published_at >= sqlc.narg('since'))
+AND (CAST(sqlc.narg('before') AS TIMESTAMP) IS NULL OR posts.published_at < sqlc.narg('before'))
+AND (CAST(sqlc.narg('keyword') AS TEXT) IS NULL
+    OR posts.title LIKE '%' || sqlc.narg('keyword') || '%' ESCAPE '\'
+    OR posts.description LIKE '%' || sqlc.narg('keyword') || '%' ESCAPE '\')
+AND (CAST(sqlc.narg('after_published_at') AS TIMESTAMP) IS NULL
+    OR (CAST(sqlc.arg('sort_order') AS TEXT) = 'oldest' AND (posts.published_at, posts.id) > (sqlc.narg('after_published_at'), sqlc.narg('after_id')))
+    OR (sqlc.arg('sort_order') <> 'oldest' AND (posts.published_at, posts.id) < (sqlc.narg('after_published_at'), sqlc.narg('after_id'))))
+ORDER BY
+    CASE WHEN sqlc.arg('sort_order') = 'feed' THEN feeds.name END ASC,
+    CASE WHEN sqlc.arg('sort_order') = 'oldest' THEN posts.published_at END ASC,
+    CASE WHEN sqlc.arg('sort_order') = 'oldest' THEN posts.id END ASC,
+    posts.published_at DESC,
+    posts.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: SearchPostsForUser :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    CAST(-bm25(posts_search, 2.0, 1.0) AS REAL) AS rank,
+    snippet(posts_search, 1, '**', '**', '...', 30) AS snippet
+FROM posts_search
+INNER JOIN posts ON posts.rowid = posts_search.rowid
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE posts_search MATCH sqlc.arg('query')
+AND posts.feed_id IN (
+    SELECT feed_id
+    FROM feed_follows
+    WHERE user_id = sqlc.arg('user_id')
+)
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,37 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, name, password_hash)
+VALUES (
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5
+)
+RETURNING *;
+
+-- name: GetUser :one
+SELECT * FROM users
+WHERE id = ?1;
+
+-- name: GetUserByName :one
+SELECT * FROM users
+WHERE name = ?1;
+
+-- name: DeleteUsers :exec
+DELETE FROM users;
+
+-- name: GetUsers :many
+SELECT name FROM users;
+
+-- name: GetAllUsers :many
+SELECT * FROM users
+ORDER BY name ASC;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = ?1;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = ?1, updated_at = ?2
+WHERE id = ?3;